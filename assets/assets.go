@@ -0,0 +1,21 @@
+// Package assets contains the static files served under /assets/, such as CSS and JavaScript files.
+// They are embedded into the binary, so that it doesn't depend on the working directory.
+package assets
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed public
+var files embed.FS
+
+// Public returns the embedded files of the public directory.
+func Public() fs.FS {
+	public, err := fs.Sub(files, "public")
+	if err != nil {
+		panic(err)
+	}
+
+	return public
+}
@@ -0,0 +1,119 @@
+(function () {
+    "use strict";
+
+    var delay = 300;
+
+    function setupPreview(input) {
+        var preview = document.querySelector(input.dataset.preview);
+        var url = input.dataset.previewUrl;
+        var form = input.form;
+        var timeout = null;
+        var pending = null;
+
+        if (!preview || !url || !window.fetch) {
+            return;
+        }
+
+        function update() {
+            var controller = window.AbortController ? new AbortController() : null;
+
+            if (pending) {
+                pending.abort();
+            }
+            pending = controller;
+
+            fetch(url, {
+                method: "POST",
+                body: new URLSearchParams(new FormData(form)),
+                credentials: "same-origin",
+                signal: controller ? controller.signal : undefined
+            }).then(function (response) {
+                if (!response.ok) {
+                    throw new Error(response.statusText);
+                }
+                return response.text();
+            }).then(function (html) {
+                preview.innerHTML = html;
+                preview.classList.remove("is-stale");
+            }).catch(function (err) {
+                if (err.name !== "AbortError") {
+                    preview.classList.add("is-stale");
+                }
+            });
+        }
+
+        function schedule() {
+            clearTimeout(timeout);
+            timeout = setTimeout(update, delay);
+        }
+
+        input.addEventListener("input", schedule);
+
+        // The preview is rejected until the title is valid, e.g. while a new article has none yet.
+        if (form.elements.title) {
+            form.elements.title.addEventListener("input", schedule);
+        }
+
+        if (form.elements.format) {
+            form.elements.format.addEventListener("change", update);
+        }
+
+        update();
+    }
+
+    function insertText(input, text) {
+        var start = input.selectionStart;
+        var end = input.selectionEnd;
+
+        input.value = input.value.slice(0, start) + text + input.value.slice(end);
+        input.selectionStart = input.selectionEnd = start + text.length;
+        input.dispatchEvent(new Event("input"));
+    }
+
+    function setupUpload(upload) {
+        var input = document.querySelector(upload.dataset.editor);
+        var url = upload.dataset.uploadUrl;
+        var title = upload.form.elements.title;
+
+        if (!input || !url || !window.fetch) {
+            return;
+        }
+
+        upload.addEventListener("change", function () {
+            var path = title.value.trim().replace(/^\/+|\/+$/g, "");
+            var data = new FormData();
+
+            if (!path) {
+                alert("Please enter a title before uploading attachments.");
+                upload.value = "";
+                return;
+            }
+
+            data.append("file", upload.files[0]);
+            if (upload.form.elements.format) {
+                data.append("format", upload.form.elements.format.value);
+            }
+
+            fetch(url + path, {
+                method: "POST",
+                body: data,
+                credentials: "same-origin",
+                headers: { "Accept": "application/json" }
+            }).then(function (response) {
+                if (!response.ok) {
+                    throw new Error(response.statusText);
+                }
+                return response.json();
+            }).then(function (attachment) {
+                insertText(input, attachment.link);
+            }).catch(function (err) {
+                alert("Upload failed: " + err.message);
+            }).then(function () {
+                upload.value = "";
+            });
+        });
+    }
+
+    document.querySelectorAll("textarea[data-preview]").forEach(setupPreview);
+    document.querySelectorAll("input[data-upload-url]").forEach(setupUpload);
+})();
@@ -0,0 +1,180 @@
+// Package cli implements the commands of the alexandria binary, which start the server or administrate the wiki
+// from the command line, e.g. when no admin can log in anymore.
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+
+	"alexandria.app/models"
+)
+
+// Version is the version of the binary. It is set when building a release.
+var Version = "dev"
+
+// An Env is what commands run with: the config and the standard streams.
+type Env struct {
+	Config *models.Config
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+type command struct {
+	usage string
+	help  string
+	run   func(env *Env, args []string) error
+}
+
+// commands are all commands by their name. Commands with subcommands, e.g. "user add", are listed with the
+// full name.
+var commands = map[string]*command{}
+
+func init() {
+	commands["serve"] = &command{"serve", "Start the web server. This is the default if no command is given.", serve}
+	commands["user add"] = &command{"user add [--admin] <email> <display name>", "Create a user. The password is read from stdin.", withLock(userAdd)}
+	commands["user list"] = &command{"user list", "List all users.", userList}
+	commands["user delete"] = &command{"user delete <email>", "Delete a user and their sessions.", withLock(userDelete)}
+	commands["user set-password"] = &command{"user set-password <email>", "Set a user's password, which is read from stdin. Logs the user out everywhere.", withLock(userSetPassword)}
+	commands["user promote"] = &command{"user promote [--revoke] <email>", "Make a user an admin, or revoke their admin rights.", withLock(userPromote)}
+	commands["session purge"] = &command{"session purge [--user <email>]", "Log out all users, or only the given one.", withLock(sessionPurge)}
+	commands["reindex"] = &command{"reindex", "Remove derived data such as thumbnails, so that it is generated again from the content.", withLock(reindex)}
+	commands["check"] = &command{"check [--fix]", "Check the config, the user database and the content for problems, e.g. articles which can't be read.", check}
+	commands["version"] = &command{"version", "Print the version.", version}
+	commands["help"] = &command{"help", "Print this help.", help}
+}
+
+// withLock runs the command while holding the lock on the data directory.
+func withLock(run func(env *Env, args []string) error) func(env *Env, args []string) error {
+	return func(env *Env, args []string) error {
+		lock, err := lockData(env)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		return run(env, args)
+	}
+}
+
+// lockData locks the data directory, so that the command can't change the data while the server or another
+// command does.
+func lockData(env *Env) (*models.DataLock, error) {
+	lock, err := models.LockData(env.Config.LockPath)
+	if errors.Is(err, models.ErrDataLocked) {
+		return nil, fmt.Errorf("%w, stop the server first", err)
+	}
+
+	return lock, err
+}
+
+// ErrUsage is returned if a command was called with the wrong arguments.
+var ErrUsage = errors.New("invalid arguments")
+
+// Run runs the command named by the first arguments, e.g. "user add" for ["user", "add", ...], or the server if
+// there are no arguments.
+func Run(env *Env, args []string) error {
+	if len(args) == 0 {
+		return serve(env, nil)
+	}
+
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok && len(args) > 1 {
+		name = args[0] + " " + args[1]
+		cmd, ok = commands[name]
+	}
+	if !ok {
+		help(env, nil)
+		return fmt.Errorf("unknown command %q", strings.Join(args, " "))
+	}
+
+	err := cmd.run(env, args[len(strings.Fields(name)):])
+	if errors.Is(err, ErrUsage) {
+		fmt.Fprintf(env.Stderr, "Usage: alexandria %s\n", cmd.usage)
+	}
+
+	return err
+}
+
+func help(env *Env, args []string) error {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(env.Stdout, "Usage: alexandria [flags] [command]")
+	fmt.Fprintln(env.Stdout, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(env.Stdout, "  %s\n    \t%s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintln(env.Stdout, "\nRun alexandria --help to list the flags.")
+
+	return nil
+}
+
+func version(env *Env, args []string) error {
+	fmt.Fprintf(env.Stdout, "alexandria %s\n", Version)
+	return nil
+}
+
+// parseFlags parses the command's flags and checks the number of remaining arguments.
+func parseFlags(env *Env, flags *flag.FlagSet, args []string, argCount int) ([]string, error) {
+	flags.SetOutput(env.Stderr)
+	if err := flags.Parse(args); err != nil {
+		return nil, ErrUsage
+	}
+
+	if flags.NArg() != argCount {
+		return nil, ErrUsage
+	}
+
+	return flags.Args(), nil
+}
+
+// readPassword reads a password from stdin. On a terminal the password isn't echoed and has to be entered twice,
+// otherwise the first line is the password, e.g. when it is piped in by a script.
+func readPassword(env *Env) (string, error) {
+	if file, ok := env.Stdin.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		fmt.Fprint(env.Stderr, "Password: ")
+		password, err := term.ReadPassword(int(file.Fd()))
+		fmt.Fprintln(env.Stderr)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprint(env.Stderr, "Repeat password: ")
+		repeated, err := term.ReadPassword(int(file.Fd()))
+		fmt.Fprintln(env.Stderr)
+		if err != nil {
+			return "", err
+		}
+
+		if string(password) != string(repeated) {
+			return "", errors.New("passwords don't match")
+		}
+
+		return string(password), nil
+	}
+
+	line, err := bufio.NewReader(env.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return "", errors.New("no password given on stdin")
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if len(password) == 0 {
+		return "", errors.New("the password must not be empty")
+	}
+
+	return password, nil
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alexandria.app/models"
+)
+
+func TestUserCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &models.Config{UserStoragePath: filepath.Join(dir, "users.db"), SessionPath: filepath.Join(dir, "sessions.db"), LockPath: filepath.Join(dir, "alexandria.lock")}
+
+	run := func(stdin string, args ...string) (string, error) {
+		var stdout, stderr bytes.Buffer
+		err := Run(&Env{Config: config, Stdin: strings.NewReader(stdin), Stdout: &stdout, Stderr: &stderr}, args)
+		return stdout.String(), err
+	}
+
+	if _, err = run("secret\n", "user", "add", "first@example.com", "First"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = run("secret\n", "user", "add", "second@example.com", "Second"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := run("", "user", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admins := map[string]string{}
+	for _, line := range strings.Split(out, "\n")[1:] {
+		if fields := strings.Fields(line); len(fields) != 0 {
+			admins[fields[1]] = fields[3]
+		}
+	}
+	if admins["first@example.com"] != "true" || admins["second@example.com"] != "false" {
+		t.Errorf("Only the first user should be an admin:\n%s", out)
+	}
+
+	if _, err = run("", "user", "promote", "--revoke", "first@example.com"); err == nil {
+		t.Error("The last admin shouldn't be demoted")
+	}
+
+	if _, err = run("", "user", "delete", "first@example.com"); err == nil {
+		t.Error("The last admin shouldn't be deleted")
+	}
+
+	if _, err = run("", "user", "promote", "second@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = run("", "user", "delete", "first@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	userStorage, err := models.LoadUserStorage(config.UserStoragePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users := userStorage.GetUsers(); len(users) != 1 || !users[0].Admin {
+		t.Errorf("Only the promoted second user should be left: %+v", users)
+	}
+
+	if _, err = run("", "user", "add", "third@example.com"); err != ErrUsage {
+		t.Errorf("Missing display name should be a usage error, got %v", err)
+	}
+
+	if _, err = run("secret\n", "user", "add", "third@example.com", "  "); err == nil {
+		t.Error("Empty display name should be an error")
+	}
+
+	if _, err = run("", "user", "frobnicate"); err == nil {
+		t.Error("Unknown command should be an error")
+	}
+
+	lock, err := models.LockData(config.LockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = run("", "user", "delete", "second@example.com"); !errors.Is(err, models.ErrDataLocked) {
+		t.Errorf("Commands changing the data should fail while the data directory is locked, got %v", err)
+	}
+	if _, err = run("", "user", "list"); err != nil {
+		t.Errorf("Commands only reading the data should work while the data directory is locked, got %v", err)
+	}
+	if err = lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
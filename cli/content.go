@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"alexandria.app/models"
+)
+
+func reindex(env *Env, args []string) error {
+	if len(args) != 0 {
+		return ErrUsage
+	}
+
+	if err := os.RemoveAll(env.Config.CachePath); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(env.Stdout, "Removed the cache, it is filled again as articles are viewed.")
+	return nil
+}
+
+func check(env *Env, args []string) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	fix := flags.Bool("fix", false, "repair the problems which can be fixed automatically")
+	if _, err := parseFlags(env, flags, args, 0); err != nil {
+		return err
+	}
+
+	// Only fixing problems writes to the data directory, checking works while the server is running.
+	if *fix {
+		lock, err := lockData(env)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+	}
+
+	// Only the users are checked, the other databases don't have to be loaded.
+	userStorage, err := models.LoadUserStorage(env.Config.UserStoragePath)
+	if err != nil {
+		return err
+	}
+
+	problems, err := models.CheckConsistency(env.Config, userStorage, *fix)
+	if err != nil {
+		return err
+	}
+
+	remaining, fixable := 0, 0
+	for _, p := range problems {
+		fmt.Fprintln(env.Stdout, p)
+		if !p.Fixed {
+			remaining++
+		}
+		if p.Fixable && !p.Fixed {
+			fixable++
+		}
+	}
+
+	if remaining != 0 {
+		if !*fix && fixable != 0 {
+			fmt.Fprintf(env.Stdout, "Run alexandria check --fix to repair %d of them.\n", fixable)
+		}
+		return fmt.Errorf("found %d problems", remaining)
+	}
+
+	fmt.Fprintln(env.Stdout, "The config is valid, the user database can be loaded and no problems were found.")
+	return nil
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"alexandria.app/models"
+	"alexandria.app/server"
+)
+
+// storages are the databases of the wiki.
+type storages struct {
+	users     models.UserStorage
+	sessions  *models.SessionStorage
+	webhooks  *models.WebhookStorage
+	revisions *models.RevisionStorage
+}
+
+// loadStorages loads all databases from the config's data path.
+func loadStorages(config *models.Config) (*storages, error) {
+	s := &storages{}
+	var err error
+
+	if s.users, err = models.LoadUserStorage(config.UserStoragePath); err != nil {
+		return nil, err
+	}
+
+	if s.webhooks, err = models.LoadWebhookStorage(config.WebhookPath); err != nil {
+		return nil, err
+	}
+
+	if s.revisions, err = models.LoadRevisionStorage(config.RevisionPath); err != nil {
+		return nil, err
+	}
+
+	if s.sessions, err = models.LoadSessionStorage(config.SessionPath, s.users); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func serve(env *Env, args []string) error {
+	if len(args) != 0 {
+		return ErrUsage
+	}
+
+	lock, err := models.LockData(env.Config.LockPath)
+	if errors.Is(err, models.ErrDataLocked) {
+		return fmt.Errorf("%w, is the server already running?", err)
+	} else if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	s, err := loadStorages(env.Config)
+	if err != nil {
+		return err
+	}
+
+	return server.Start(s.users, s.sessions, s.webhooks, s.revisions, env.Config)
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func sessionPurge(env *Env, args []string) error {
+	flags := flag.NewFlagSet("session purge", flag.ContinueOnError)
+	email := flags.String("user", "", "only log out the user with this email")
+	if _, err := parseFlags(env, flags, args, 0); err != nil {
+		return err
+	}
+
+	if len(*email) != 0 {
+		userStorage, user, err := findUser(env, *email)
+		if err != nil {
+			return err
+		}
+
+		if err = removeSessions(env, userStorage, user); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(env.Stdout, "Logged out %s.\n", user.Email)
+		return nil
+	}
+
+	if err := os.Remove(env.Config.SessionPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	fmt.Fprintln(env.Stdout, "Logged out all users.")
+	return nil
+}
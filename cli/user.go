@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/mail"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"alexandria.app/models"
+)
+
+// findUser loads the user storage and looks up the user with the email.
+func findUser(env *Env, email string) (models.UserStorage, *models.User, error) {
+	userStorage, err := models.LoadUserStorage(env.Config.UserStoragePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user := userStorage.GetUser(email)
+	if user == nil {
+		return nil, nil, fmt.Errorf("no user with the email %q", email)
+	}
+
+	return userStorage, user, nil
+}
+
+// removeSessions logs the user out everywhere.
+func removeSessions(env *Env, userStorage models.UserStorage, user *models.User) error {
+	sessionStorage, err := models.LoadSessionStorage(env.Config.SessionPath, userStorage)
+	if err != nil {
+		return err
+	}
+
+	sessionStorage.RemoveSessionsForUser(user.ID)
+	return sessionStorage.Save()
+}
+
+// isLastAdmin checks if the user is the only admin, who has to be kept so that the wiki can be administrated.
+func isLastAdmin(userStorage models.UserStorage, user *models.User) bool {
+	if !user.Admin {
+		return false
+	}
+
+	for _, u := range userStorage.GetUsers() {
+		if u.Admin && u.ID != user.ID {
+			return false
+		}
+	}
+
+	return true
+}
+
+func userAdd(env *Env, args []string) error {
+	flags := flag.NewFlagSet("user add", flag.ContinueOnError)
+	admin := flags.Bool("admin", false, "make the user an admin")
+	args, err := parseFlags(env, flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	parsedEmail, err := mail.ParseAddress(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid email address %q", args[0])
+	}
+
+	displayName := strings.TrimSpace(args[1])
+	if len(displayName) == 0 {
+		return errors.New("the display name must not be empty")
+	}
+
+	password, err := readPassword(env)
+	if err != nil {
+		return err
+	}
+
+	userStorage, err := models.LoadUserStorage(env.Config.UserStoragePath)
+	if err != nil {
+		return err
+	}
+
+	// The first user has to be an admin, as in the setup.
+	user, err := models.NewUser(parsedEmail.Address, displayName, password, *admin || userStorage.IsEmpty())
+	if err != nil {
+		return err
+	}
+
+	if err = userStorage.AddUser(user); err != nil {
+		return err
+	}
+
+	if err = userStorage.Save(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.Stdout, "Created user %s with the ID %d.\n", user.Email, user.ID)
+	return nil
+}
+
+func userList(env *Env, args []string) error {
+	if len(args) != 0 {
+		return ErrUsage
+	}
+
+	userStorage, err := models.LoadUserStorage(env.Config.UserStoragePath)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEmail\tDisplay name\tAdmin\tCreated")
+	for _, user := range userStorage.GetUsers() {
+		created := time.Unix(user.CreationDate, 0).Format("2006-01-02 15:04")
+		fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%s\n", user.ID, user.Email, user.DisplayName, user.Admin, created)
+	}
+
+	return w.Flush()
+}
+
+func userDelete(env *Env, args []string) error {
+	if len(args) != 1 {
+		return ErrUsage
+	}
+
+	userStorage, user, err := findUser(env, args[0])
+	if err != nil {
+		return err
+	}
+
+	if isLastAdmin(userStorage, user) {
+		return errors.New("the last admin can't be deleted")
+	}
+
+	userStorage.DeleteUser(user.ID)
+	if err = userStorage.Save(); err != nil {
+		return err
+	}
+
+	if err = removeSessions(env, userStorage, user); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.Stdout, "Deleted user %s.\n", user.Email)
+	return nil
+}
+
+func userSetPassword(env *Env, args []string) error {
+	if len(args) != 1 {
+		return ErrUsage
+	}
+
+	userStorage, user, err := findUser(env, args[0])
+	if err != nil {
+		return err
+	}
+
+	password, err := readPassword(env)
+	if err != nil {
+		return err
+	}
+
+	if err = userStorage.SetUserPassword(user, password); err != nil {
+		return err
+	}
+
+	if err = userStorage.Save(); err != nil {
+		return err
+	}
+
+	if err = removeSessions(env, userStorage, user); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.Stdout, "Changed the password of %s.\n", user.Email)
+	return nil
+}
+
+func userPromote(env *Env, args []string) error {
+	flags := flag.NewFlagSet("user promote", flag.ContinueOnError)
+	revoke := flags.Bool("revoke", false, "revoke the admin rights instead")
+	args, err := parseFlags(env, flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	userStorage, user, err := findUser(env, args[0])
+	if err != nil {
+		return err
+	}
+
+	if *revoke && isLastAdmin(userStorage, user) {
+		return errors.New("the last admin can't be demoted")
+	}
+
+	user.Admin = !*revoke
+	if err = userStorage.Save(); err != nil {
+		return err
+	}
+
+	if user.Admin {
+		fmt.Fprintf(env.Stdout, "%s is an admin now.\n", user.Email)
+	} else {
+		fmt.Fprintf(env.Stdout, "%s is no admin anymore.\n", user.Email)
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package diff
+
+import (
+	"strings"
+)
+
+// Operation describes what happened to a line when going from the old to the new text.
+type Operation int
+
+// All possible operations of a diff.
+const (
+	Equal Operation = iota
+	Insert
+	Delete
+)
+
+// String returns the name of the operation, which is also used as CSS class in diff views.
+func (op Operation) String() string {
+	switch op {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// A Line is a single line of a diff.
+type Line struct {
+	Op   Operation
+	Text string
+}
+
+// Summary contains the number of added and removed lines of a diff.
+type Summary struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// Lines computes the line based diff between the old text a and the new text b.
+func Lines(a, b string) []Line {
+	return Sequences(splitLines(a), splitLines(b))
+}
+
+// Sequences computes the diff between two sequences of arbitrary strings, for example paragraphs.
+func Sequences(oldLines, newLines []string) []Line {
+	ops := compute(oldLines, newLines)
+
+	lines := make([]Line, len(ops))
+	for i, op := range ops {
+		if op.op == Insert {
+			lines[i] = Line{Op: Insert, Text: newLines[op.index]}
+		} else {
+			lines[i] = Line{Op: op.op, Text: oldLines[op.index]}
+		}
+	}
+
+	return lines
+}
+
+// Summarize counts the added and removed lines of a diff.
+func Summarize(lines []Line) Summary {
+	s := Summary{}
+
+	for _, l := range lines {
+		switch l.Op {
+		case Insert:
+			s.Added++
+		case Delete:
+			s.Removed++
+		}
+	}
+
+	return s
+}
+
+func splitLines(s string) []string {
+	if len(s) == 0 {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// edit is a single step of the edit script. index refers to the old sequence for
+// Equal and Delete operations and to the new sequence for Insert operations.
+type edit struct {
+	op    Operation
+	index int
+}
+
+// maxCost limits the work of a diff, measured as the number of edits explored times the length of the
+// compared sequences. Sequences which differ more are diffed coarsely, see differ.diff.
+const maxCost = 1 << 24
+
+// compute implements the linear space variant of Myers' O(ND) difference algorithm and returns an edit script
+// which transforms a into b. The script is the shortest one unless the sequences differ too much to find it
+// within maxCost.
+func compute(a, b []string) []edit {
+	// Both searches of the middle snake need at most (n+m+1)/2 + 1 diagonals on each side.
+	size := (len(a)+len(b)+1)/2 + 2
+	d := &differ{a: a, b: b, forward: make([]int, 2*size+1), backward: make([]int, 2*size+1)}
+	d.edits = make([]edit, 0, len(a)+len(b))
+
+	d.diff(0, len(a), 0, len(b))
+
+	return d.edits
+}
+
+// differ holds the state of a diff. The arrays of the furthest reaching paths are shared by all steps of the
+// recursion, as only one step searches at a time.
+type differ struct {
+	a, b              []string
+	forward, backward []int
+	edits             []edit
+}
+
+// diff appends the edits which transform a[aLo:aHi] into b[bLo:bHi]. The sequences are split at the middle of
+// their shortest edit script, and both halves are diffed recursively.
+func (d *differ) diff(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.edits = append(d.edits, edit{op: Equal, index: aLo})
+		aLo++
+		bLo++
+	}
+
+	suffix := 0
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+		suffix++
+	}
+
+	if x, y, ok := d.middleSnake(aLo, aHi, bLo, bHi); ok {
+		d.diff(aLo, x, bLo, y)
+		d.diff(x, aHi, y, bHi)
+	} else {
+		// Either one of the sequences is empty, or they differ too much, then the whole part is replaced.
+		for i := aLo; i < aHi; i++ {
+			d.edits = append(d.edits, edit{op: Delete, index: i})
+		}
+		for i := bLo; i < bHi; i++ {
+			d.edits = append(d.edits, edit{op: Insert, index: i})
+		}
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.edits = append(d.edits, edit{op: Equal, index: aHi + i})
+	}
+}
+
+// middleSnake searches the shortest edit script of a[aLo:aHi] and b[bLo:bHi] from both ends at once and returns
+// a point on it where the searches meet. As the sequences neither start nor end with the same element, the point
+// splits the script into two shorter ones. ok is false if either sequence is empty or the search exceeds maxCost.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x, y int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+
+	delta := n - m
+	odd := delta%2 != 0
+	offset := (n+m+1)/2 + 1
+	vf, vb := d.forward, d.backward
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	// Diagonal k of the backward search, which runs from the ends of the sequences, is diagonal delta-k of the
+	// forward search.
+	for D := 0; D <= (n+m+1)/2; D++ {
+		if D*(n+m) > maxCost {
+			return 0, 0, false
+		}
+
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			if odd && delta-k >= -(D-1) && delta-k <= D-1 && x+vb[offset+delta-k] >= n {
+				return aLo + x, bLo + y, true
+			}
+		}
+
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vb[offset+k-1] < vb[offset+k+1]) {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+
+			if !odd && delta-k >= -D && delta-k <= D && x+vf[offset+delta-k] >= n {
+				return aHi - x, bHi - y, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,215 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLines(t *testing.T) {
+	old := "a\nb\nc\nd\n"
+	new := "a\nc\nd\ne\n"
+
+	lines := Lines(old, new)
+
+	expected := []Line{
+		{Op: Equal, Text: "a"},
+		{Op: Delete, Text: "b"},
+		{Op: Equal, Text: "c"},
+		{Op: Equal, Text: "d"},
+		{Op: Insert, Text: "e"},
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("Diff has %v lines, should have %v: %v", len(lines), len(expected), lines)
+	}
+
+	for i, l := range lines {
+		if l != expected[i] {
+			t.Errorf("Line %v is %v, should be %v", i, l, expected[i])
+		}
+	}
+
+	summary := Summarize(lines)
+	if summary.Added != 1 || summary.Removed != 1 {
+		t.Errorf("Summary is %+v, should be {Added:1 Removed:1}", summary)
+	}
+}
+
+func TestLinesEmpty(t *testing.T) {
+	lines := Lines("", "foo\nbar")
+
+	summary := Summarize(lines)
+	if summary.Added != 2 || summary.Removed != 0 {
+		t.Errorf("Summary is %+v, should be {Added:2 Removed:0}", summary)
+	}
+
+	lines = Lines("foo\nbar", "")
+
+	summary = Summarize(lines)
+	if summary.Added != 0 || summary.Removed != 2 {
+		t.Errorf("Summary is %+v, should be {Added:0 Removed:2}", summary)
+	}
+
+	if len(Lines("", "")) != 0 {
+		t.Error("Diff of two empty texts should be empty")
+	}
+}
+
+func TestUnified(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\n"
+
+	expected := `--- old
++++ new
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+@@ -8 +8,2 @@
+ h
++i
+`
+
+	unified := Unified(Lines(old, new), "old", "new", 1)
+	if unified != expected {
+		t.Errorf("Unified diff is\n%v\nshould be\n%v", unified, expected)
+	}
+
+	if Unified(Lines(old, old), "old", "new", 3) != "" {
+		t.Error("Unified diff of identical texts should be empty")
+	}
+}
+
+func TestWords(t *testing.T) {
+	oldSegments, newSegments := Words("the quick brown fox", "the slow brown fox!")
+
+	expectedOld := []Segment{{Equal, "the "}, {Delete, "quick"}, {Equal, " brown fox"}}
+	expectedNew := []Segment{{Equal, "the "}, {Insert, "slow"}, {Equal, " brown fox"}, {Insert, "!"}}
+
+	if len(oldSegments) != len(expectedOld) || len(newSegments) != len(expectedNew) {
+		t.Fatalf("Segments are %v and %v, should be %v and %v", oldSegments, newSegments, expectedOld, expectedNew)
+	}
+
+	for i := range oldSegments {
+		if oldSegments[i] != expectedOld[i] {
+			t.Errorf("Old segment %v is %v, should be %v", i, oldSegments[i], expectedOld[i])
+		}
+	}
+
+	for i := range newSegments {
+		if newSegments[i] != expectedNew[i] {
+			t.Errorf("New segment %v is %v, should be %v", i, newSegments[i], expectedNew[i])
+		}
+	}
+}
+
+func TestRows(t *testing.T) {
+	rows := Rows(Lines("a\nb\nc\n", "a\nB\nx\nc\n"))
+
+	if len(rows) != 4 {
+		t.Fatalf("Diff has %v rows, should have 4", len(rows))
+	}
+
+	if rows[1].Old == nil || rows[1].New == nil || rows[1].Old.Number != 2 || rows[1].New.Number != 2 {
+		t.Errorf("Changed lines should be paired, got %+v", rows[1])
+	}
+
+	if rows[2].Old != nil || rows[2].New == nil || rows[2].New.Number != 3 {
+		t.Errorf("Inserted line should only have a new cell, got %+v", rows[2])
+	}
+
+	if rows[3].Old.Number != 3 || rows[3].New.Number != 4 {
+		t.Errorf("Line numbers of last row are %v and %v, should be 3 and 4", rows[3].Old.Number, rows[3].New.Number)
+	}
+
+	cells := Inline(rows)
+	ops := []Operation{Equal, Delete, Insert, Insert, Equal}
+
+	if len(cells) != len(ops) {
+		t.Fatalf("Inline diff has %v lines, should have %v", len(cells), len(ops))
+	}
+
+	for i, c := range cells {
+		if c.Op != ops[i] {
+			t.Errorf("Inline line %v has operation %v, should be %v", i, c.Op, ops[i])
+		}
+	}
+}
+
+// checkScript checks that the diff turns oldLines into newLines and returns its number of insertions and deletions.
+func checkScript(t *testing.T, oldLines, newLines []string, lines []Line) int {
+	var a, b []string
+	for _, l := range lines {
+		if l.Op != Insert {
+			a = append(a, l.Text)
+		}
+		if l.Op != Delete {
+			b = append(b, l.Text)
+		}
+	}
+
+	if strings.Join(a, "\n") != strings.Join(oldLines, "\n") || strings.Join(b, "\n") != strings.Join(newLines, "\n") {
+		t.Fatalf("Diff of %v and %v doesn't reproduce them: %v", oldLines, newLines, lines)
+	}
+
+	s := Summarize(lines)
+	return s.Added + s.Removed
+}
+
+func TestSequencesShortest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() []string {
+		s := make([]string, r.Intn(30))
+		for i := range s {
+			s[i] = string(rune('a' + r.Intn(4)))
+		}
+		return s
+	}
+
+	for i := 0; i < 500; i++ {
+		oldLines, newLines := random(), random()
+
+		// The shortest edit script keeps the longest common subsequence.
+		lcs := make([][]int, len(oldLines)+1)
+		for x := range lcs {
+			lcs[x] = make([]int, len(newLines)+1)
+		}
+		for x := len(oldLines) - 1; x >= 0; x-- {
+			for y := len(newLines) - 1; y >= 0; y-- {
+				if oldLines[x] == newLines[y] {
+					lcs[x][y] = lcs[x+1][y+1] + 1
+				} else if lcs[x+1][y] > lcs[x][y+1] {
+					lcs[x][y] = lcs[x+1][y]
+				} else {
+					lcs[x][y] = lcs[x][y+1]
+				}
+			}
+		}
+
+		if edits, shortest := checkScript(t, oldLines, newLines, Sequences(oldLines, newLines)), len(oldLines)+len(newLines)-2*lcs[0][0]; edits != shortest {
+			t.Errorf("Diff of %v and %v has %d edits, the shortest has %d", oldLines, newLines, edits, shortest)
+		}
+	}
+}
+
+func TestLinesRewritten(t *testing.T) {
+	oldLines, newLines := make([]string, 10000), make([]string, 10000)
+	for i := range oldLines {
+		oldLines[i] = fmt.Sprintf("old %d", i)
+		newLines[i] = fmt.Sprintf("new %d", i)
+	}
+	// A common line keeps the diff from being trivial.
+	newLines[5000] = oldLines[5000]
+
+	start := time.Now()
+	lines := Sequences(oldLines, newLines)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Diff of a rewritten text took %v", elapsed)
+	}
+
+	checkScript(t, oldLines, newLines, lines)
+}
@@ -0,0 +1,181 @@
+package diff
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Segment is a part of a line. Segments are used to highlight the changed words within a changed line.
+type Segment struct {
+	Op   Operation
+	Text string
+}
+
+// A Cell is a single line of either the old or the new text, as displayed in a diff view.
+type Cell struct {
+	Number   int
+	Op       Operation
+	Segments []Segment
+}
+
+// A Row contains the old and the new version of a line, for displaying diffs side by side.
+// For inserted lines Old is nil, for deleted lines New is nil.
+type Row struct {
+	Old *Cell
+	New *Cell
+}
+
+// Words computes the word level diff between two lines.
+// It returns the segments of the old and the new line, where unchanged segments have the Equal operation.
+func Words(a, b string) (oldSegments []Segment, newSegments []Segment) {
+	oldWords := splitWords(a)
+	newWords := splitWords(b)
+
+	for _, e := range compute(oldWords, newWords) {
+		switch e.op {
+		case Equal:
+			oldSegments = appendSegment(oldSegments, Equal, oldWords[e.index])
+			newSegments = appendSegment(newSegments, Equal, oldWords[e.index])
+		case Delete:
+			oldSegments = appendSegment(oldSegments, Delete, oldWords[e.index])
+		case Insert:
+			newSegments = appendSegment(newSegments, Insert, newWords[e.index])
+		}
+	}
+
+	return oldSegments, newSegments
+}
+
+// appendSegment merges consecutive words with the same operation into a single segment.
+func appendSegment(segments []Segment, op Operation, text string) []Segment {
+	if len(segments) != 0 && segments[len(segments)-1].Op == op {
+		segments[len(segments)-1].Text += text
+		return segments
+	}
+
+	return append(segments, Segment{Op: op, Text: text})
+}
+
+// splitWords splits a line into words, whitespace runs and single punctuation characters.
+func splitWords(s string) []string {
+	words := []string{}
+
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		default:
+			return 0
+		}
+	}
+
+	start := 0
+	for start < len(s) {
+		r, size := utf8.DecodeRuneInString(s[start:])
+		c := class(r)
+		end := start + size
+
+		for c != 0 && end < len(s) {
+			next, nextSize := utf8.DecodeRuneInString(s[end:])
+			if class(next) != c {
+				break
+			}
+			end += nextSize
+		}
+
+		words = append(words, s[start:end])
+		start = end
+	}
+
+	return words
+}
+
+// Rows pairs the lines of a diff for displaying them side by side.
+// Deleted and inserted lines of the same change are paired up and get highlighted on word level.
+func Rows(lines []Line) []Row {
+	rows := []Row{}
+	oldNumber, newNumber := 0, 0
+
+	i := 0
+	for i < len(lines) {
+		if lines[i].Op == Equal {
+			oldNumber++
+			newNumber++
+			segments := []Segment{{Op: Equal, Text: lines[i].Text}}
+			rows = append(rows, Row{
+				Old: &Cell{Number: oldNumber, Op: Equal, Segments: segments},
+				New: &Cell{Number: newNumber, Op: Equal, Segments: segments},
+			})
+			i++
+			continue
+		}
+
+		deleted, inserted := []string{}, []string{}
+		for i < len(lines) && lines[i].Op != Equal {
+			if lines[i].Op == Delete {
+				deleted = append(deleted, lines[i].Text)
+			} else {
+				inserted = append(inserted, lines[i].Text)
+			}
+			i++
+		}
+
+		for j := 0; j < len(deleted) || j < len(inserted); j++ {
+			row := Row{}
+
+			switch {
+			case j < len(deleted) && j < len(inserted):
+				oldSegments, newSegments := Words(deleted[j], inserted[j])
+				oldNumber++
+				newNumber++
+				row.Old = &Cell{Number: oldNumber, Op: Delete, Segments: oldSegments}
+				row.New = &Cell{Number: newNumber, Op: Insert, Segments: newSegments}
+			case j < len(deleted):
+				oldNumber++
+				row.Old = &Cell{Number: oldNumber, Op: Delete, Segments: []Segment{{Op: Delete, Text: deleted[j]}}}
+			default:
+				newNumber++
+				row.New = &Cell{Number: newNumber, Op: Insert, Segments: []Segment{{Op: Insert, Text: inserted[j]}}}
+			}
+
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// Inline flattens the rows of a side by side diff into a single column.
+// Within a change all old lines are listed before the new lines.
+func Inline(rows []Row) []*Cell {
+	cells := []*Cell{}
+	oldCells, newCells := []*Cell{}, []*Cell{}
+
+	flush := func() {
+		cells = append(cells, oldCells...)
+		cells = append(cells, newCells...)
+		oldCells, newCells = oldCells[:0], newCells[:0]
+	}
+
+	for _, row := range rows {
+		if row.Old != nil && row.Old.Op == Equal {
+			flush()
+			cells = append(cells, row.New)
+			continue
+		}
+
+		if row.Old != nil {
+			oldCells = append(oldCells, row.Old)
+		}
+
+		if row.New != nil {
+			newCells = append(newCells, row.New)
+		}
+	}
+
+	flush()
+
+	return cells
+}
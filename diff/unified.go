@@ -0,0 +1,125 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Unified formats a diff in the unified diff format, as used by diff -u and git.
+// context is the number of unchanged lines shown around every change.
+func Unified(lines []Line, fromName, toName string, context int) string {
+	var b strings.Builder
+
+	hunks := hunks(lines, context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+
+	for _, h := range hunks {
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(h.oldStart, h.oldLines), hunkRange(h.newStart, h.newLines))
+
+		for _, l := range h.lines {
+			switch l.Op {
+			case Equal:
+				b.WriteString(" ")
+			case Insert:
+				b.WriteString("+")
+			case Delete:
+				b.WriteString("-")
+			}
+
+			b.WriteString(l.Text)
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+type hunk struct {
+	oldStart, oldLines int
+	newStart, newLines int
+	lines              []Line
+}
+
+func hunkRange(start, lines int) string {
+	// An empty range starts at the line before, see the GNU diff documentation.
+	if lines == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// hunks groups the changed lines of a diff together with up to context unchanged lines around them.
+func hunks(lines []Line, context int) []hunk {
+	result := []hunk{}
+
+	// Line numbers in the old and new text, before lines[i].
+	oldLine, newLine := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, l := range lines {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if l.Op != Insert {
+			oldLine[i+1]++
+		}
+		if l.Op != Delete {
+			newLine[i+1]++
+		}
+	}
+
+	i := 0
+	for i < len(lines) {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk as long as the next change is close enough to be merged.
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != Equal {
+				end++
+				continue
+			}
+
+			next := end
+			for next < len(lines) && lines[next].Op == Equal {
+				next++
+			}
+
+			if next == len(lines) || next-end > 2*context {
+				break
+			}
+
+			end = next
+		}
+
+		stop := end + context
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+
+		result = append(result, hunk{
+			oldStart: oldLine[start] + 1,
+			oldLines: oldLine[stop] - oldLine[start],
+			newStart: newLine[start] + 1,
+			newLines: newLine[stop] - newLine[start],
+			lines:    lines[start:stop],
+		})
+
+		i = stop
+	}
+
+	return result
+}
@@ -1,11 +1,19 @@
 module alexandria.app
 
+go 1.27.1
+
 require (
-	github.com/BurntSushi/toml v0.3.1
+	github.com/BurntSushi/toml v1.3.2
+	github.com/alecthomas/chroma v0.10.0
 	github.com/google/uuid v1.1.0
 	github.com/gorilla/mux v1.7.0
-	github.com/russross/blackfriday/v2 v2.0.1
-	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
+	github.com/niklasfasching/go-org v1.6.2
+	github.com/shurcooL/sanitized_anchor_name v1.0.0
+	github.com/yuin/goldmark v1.7.8
 	golang.org/x/crypto v0.0.0-20190123085648-057139ce5d2b
-	golang.org/x/sys v0.0.0-20190124100055-b90733256f2e // indirect
+	golang.org/x/net v0.0.0-20201224014010-6772e930b67b
+	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
 )
+
+require github.com/dlclark/regexp2 v1.4.0 // indirect
@@ -0,0 +1,128 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// weight is the contribution of a single source pixel to a destination pixel.
+type weight struct {
+	index  int
+	weight float64
+}
+
+// Resize scales the image to the given width, keeping its aspect ratio.
+// A triangle filter is used which averages all covered source pixels, so that downscaled images don't alias.
+// Images which are already smaller than width are returned unchanged.
+func Resize(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	if width <= 0 || width >= bounds.Dx() {
+		return src
+	}
+
+	height := int(math.Round(float64(bounds.Dy()) * float64(width) / float64(bounds.Dx())))
+	if height < 1 {
+		height = 1
+	}
+
+	// Work on premultiplied alpha to avoid dark fringes around transparent areas.
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	horizontal := resample(rgba, width, bounds.Dy(), weights(bounds.Dx(), width), true)
+	return resample(horizontal, width, height, weights(bounds.Dy(), height), false)
+}
+
+// weights computes, for every destination pixel, which source pixels contribute to it and by how much.
+func weights(srcSize, dstSize int) [][]weight {
+	scale := float64(srcSize) / float64(dstSize)
+	support := math.Max(scale, 1)
+
+	result := make([][]weight, dstSize)
+
+	for i := 0; i < dstSize; i++ {
+		center := (float64(i)+0.5)*scale - 0.5
+		start := int(math.Ceil(center - support))
+		end := int(math.Floor(center + support))
+
+		sum := 0.0
+		ws := []weight{}
+
+		for j := start; j <= end; j++ {
+			w := 1 - math.Abs(float64(j)-center)/support
+			if w <= 0 {
+				continue
+			}
+
+			index := j
+			if index < 0 {
+				index = 0
+			} else if index >= srcSize {
+				index = srcSize - 1
+			}
+
+			ws = append(ws, weight{index: index, weight: w})
+			sum += w
+		}
+
+		for j := range ws {
+			ws[j].weight /= sum
+		}
+
+		result[i] = ws
+	}
+
+	return result
+}
+
+// resample scales the image along a single axis.
+func resample(src *image.RGBA, width, height int, ws [][]weight, horizontal bool) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var r, g, b, a float64
+
+			var pixelWeights []weight
+			if horizontal {
+				pixelWeights = ws[x]
+			} else {
+				pixelWeights = ws[y]
+			}
+
+			for _, w := range pixelWeights {
+				var offset int
+				if horizontal {
+					offset = src.PixOffset(w.index, y)
+				} else {
+					offset = src.PixOffset(x, w.index)
+				}
+
+				r += float64(src.Pix[offset]) * w.weight
+				g += float64(src.Pix[offset+1]) * w.weight
+				b += float64(src.Pix[offset+2]) * w.weight
+				a += float64(src.Pix[offset+3]) * w.weight
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = clamp(r)
+			dst.Pix[offset+1] = clamp(g)
+			dst.Pix[offset+2] = clamp(b)
+			dst.Pix[offset+3] = clamp(a)
+		}
+	}
+
+	return dst
+}
+
+func clamp(v float64) uint8 {
+	v = math.Round(v)
+	if v < 0 {
+		return 0
+	} else if v > 255 {
+		return 255
+	}
+
+	return uint8(v)
+}
@@ -0,0 +1,36 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			// Vertical stripes, which average to a uniform grey when downscaled.
+			if x%2 == 0 {
+				src.Set(x, y, color.RGBA{255, 255, 255, 255})
+			} else {
+				src.Set(x, y, color.RGBA{0, 0, 0, 255})
+			}
+		}
+	}
+
+	dst := Resize(src, 100)
+
+	if dst.Bounds().Dx() != 100 || dst.Bounds().Dy() != 50 {
+		t.Fatalf("Resized image is %vx%v, should be 100x50", dst.Bounds().Dx(), dst.Bounds().Dy())
+	}
+
+	r, _, _, a := dst.At(50, 25).RGBA()
+	if r>>8 < 120 || r>>8 > 135 || a>>8 != 255 {
+		t.Errorf("Pixel should be opaque grey, is r=%v a=%v", r>>8, a>>8)
+	}
+
+	if Resize(src, 800) != image.Image(src) {
+		t.Error("Images should never be upscaled")
+	}
+}
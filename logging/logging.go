@@ -0,0 +1,105 @@
+// Package logging sets up the structured logger and carries information about the current request,
+// so that every log line written while handling a request can be attributed to it.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"alexandria.app/models"
+)
+
+// RequestIDHeader is the header which carries the ID of a request in the response and, from trusted proxies,
+// in the request.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestInfo describes the request which is currently handled. The ID is set when the request arrives,
+// the route and user once they are known.
+type RequestInfo struct {
+	ID     string
+	Route  string
+	UserID uint32
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a copy of the context which carries the info.
+func WithRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// GetRequestInfo returns the info of the request the context belongs to, or nil if there is none.
+func GetRequestInfo(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info
+}
+
+// contextHandler adds the ID of the current request to all records logged with its context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if info := GetRequestInfo(ctx); info != nil {
+		record.AddAttrs(slog.String("request_id", info.ID))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// NewLogger creates a logger writing to w in the config's LogFormat, either "json" or "text", which is logfmt.
+func NewLogger(w io.Writer, config *models.Config) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if strings.ToLower(config.LogFormat) == "json" {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+// Setup makes the logger configured by the config the default logger, which the log package writes to as well.
+// The logger writes to the config's LogFile, or to stderr if it isn't set. The returned file has to be closed
+// once nothing is logged anymore.
+func Setup(config *models.Config) (io.Closer, error) {
+	file := os.Stderr
+	if len(config.LogFile) != 0 {
+		var err error
+		if file, err = os.OpenFile(config.LogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640); err != nil {
+			return nil, err
+		}
+	}
+
+	logger, err := NewLogger(file, config)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	slog.SetDefault(logger)
+
+	if file == os.Stderr {
+		return io.NopCloser(nil), nil
+	}
+
+	return file, nil
+}
@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"alexandria.app/models"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, &models.Config{LogFormat: "json", LogLevel: "warn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithRequestInfo(context.Background(), &RequestInfo{ID: "abc"})
+	logger.InfoContext(ctx, "Ignored")
+	logger.ErrorContext(ctx, "Failed", "error", "broken")
+
+	var record map[string]interface{}
+	if err = json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Only the error should be logged as JSON: %v %q", err, buf.String())
+	}
+
+	if record["msg"] != "Failed" || record["request_id"] != "abc" || record["error"] != "broken" {
+		t.Errorf("Record should contain the message, request ID and attributes: %v", record)
+	}
+
+	if _, err = NewLogger(&buf, &models.Config{LogLevel: "loud"}); err == nil {
+		t.Error("Unknown level should be an error")
+	}
+}
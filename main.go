@@ -1,19 +1,41 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"alexandria.app/cli"
+	"alexandria.app/logging"
 	"alexandria.app/models"
-	"alexandria.app/server"
 )
 
 func main() {
-	config := models.NewConfig()
+	config, args, err := models.LoadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	userStorage, err := models.LoadUserStorage(config.UserStoragePath)
+	logFile, err := logging.Setup(config)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	defer logFile.Close()
 
-	sessionStorage := models.NewSessionStorage()
+	env := &cli.Env{Config: config, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+	if err = cli.Run(env, args); err != nil {
+		if errors.Is(err, cli.ErrUsage) {
+			logFile.Close()
+			os.Exit(2)
+		}
 
-	server.Start(userStorage, sessionStorage, config)
+		fmt.Fprintf(os.Stderr, "alexandria: %v\n", err)
+		logFile.Close()
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,289 @@
+// Package metrics collects counters, histograms and gauges and exposes them in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default upper bounds of histogram buckets in seconds, suited for request latencies.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// A Registry holds metrics, which are written together.
+type Registry struct {
+	mutex   sync.RWMutex
+	metrics map[string]metric
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+// DefaultRegistry holds the metrics created by the package's functions.
+var DefaultRegistry = NewRegistry()
+
+// register adds the metric to the registry, replacing any metric with the same name.
+func (r *Registry) register(m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.metrics[m.name()] = m
+}
+
+// WriteTo writes all metrics in the Prometheus text format, sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	r.mutex.RUnlock()
+	sort.Strings(names)
+
+	buf := bufio.NewWriter(w)
+	counter := &countingWriter{w: buf}
+	for _, name := range names {
+		r.mutex.RLock()
+		m := r.metrics[name]
+		r.mutex.RUnlock()
+
+		m.write(counter)
+	}
+
+	if err := buf.Flush(); err != nil {
+		return counter.n, err
+	}
+
+	return counter.n, nil
+}
+
+// Handler serves the metrics of the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help), name, kind)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// formatLabels formats the labels as {a="1",b="2"}, with extra appended as the last label if set.
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escape.Replace(values[i])+`"`)
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escape.Replace(extra[1])+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// vec keeps track of the combinations of label values a metric was used with, each of which is a series.
+type vec struct {
+	metricName string
+	help       string
+	labels     []string
+	mutex      sync.Mutex
+	series     map[string][]string
+}
+
+func (v *vec) name() string {
+	return v.metricName
+}
+
+// key returns the key of the label values, panicking if their number doesn't match the labels.
+func (v *vec) key(values []string) string {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.metricName, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	if _, ok := v.series[key]; !ok {
+		v.series[key] = append([]string{}, values...)
+	}
+
+	return key
+}
+
+// sortedKeys returns the keys of all series in a stable order.
+func (v *vec) sortedKeys() []string {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// A Counter is a value which only goes up, e.g. the number of requests.
+type Counter struct {
+	vec
+	values map[string]float64
+}
+
+// NewCounter creates a counter with the label names and registers it in the DefaultRegistry.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: vec{metricName: name, help: help, labels: labels, series: map[string][]string{}}, values: map[string]float64{}}
+	DefaultRegistry.register(c)
+	return c
+}
+
+// Inc increments the counter with the label values by one.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter with the label values by delta, which must not be negative.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.values[c.key(labelValues)] += delta
+}
+
+// Value returns the current value of the counter with the label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.values[c.key(labelValues)]
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	writeHeader(w, c.metricName, c.help, "counter")
+	for _, key := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, formatLabels(c.labels, c.series[key]), formatValue(c.values[key]))
+	}
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// A Histogram counts observations, e.g. request durations, in buckets.
+type Histogram struct {
+	vec
+	buckets []float64
+	values  map[string]*histogramSeries
+}
+
+// NewHistogram creates a histogram with the buckets' upper bounds and the label names and registers it in the
+// DefaultRegistry.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		vec:     vec{metricName: name, help: help, labels: labels, series: map[string][]string{}},
+		buckets: append([]float64{}, buckets...),
+		values:  map[string]*histogramSeries{},
+	}
+	sort.Float64s(h.buckets)
+	DefaultRegistry.register(h)
+	return h
+}
+
+// Observe adds the value to the histogram with the label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := h.key(labelValues)
+	series, ok := h.values[key]
+	if !ok {
+		series = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = series
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	writeHeader(w, h.metricName, h.help, "histogram")
+	for _, key := range h.sortedKeys() {
+		labels, series := h.series[key], h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(h.labels, labels, "le", formatValue(bound)), series.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(h.labels, labels, "le", "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, formatLabels(h.labels, labels), formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, formatLabels(h.labels, labels), series.count)
+	}
+}
+
+// A GaugeFunc is a value which can go up and down, e.g. the number of sessions. It is read when the metrics are
+// written.
+type GaugeFunc struct {
+	metricName string
+	help       string
+	value      func() float64
+}
+
+// NewGaugeFunc creates a gauge whose value is returned by the function and registers it in the DefaultRegistry.
+// A gauge with the same name which was registered before is replaced.
+func NewGaugeFunc(name, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, value: value}
+	DefaultRegistry.register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string {
+	return g.metricName
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.metricName, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatValue(g.value()))
+}
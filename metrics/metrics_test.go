@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	DefaultRegistry = NewRegistry()
+
+	requests := NewCounter("test_requests_total", "Number of requests.", "route", "status")
+	requests.Inc("/articles", "200")
+	requests.Add(2, "/login", "401")
+	requests.Inc("/articles", "200")
+
+	durations := NewHistogram("test_duration_seconds", "Duration.", []float64{1, 0.1})
+	durations.Observe(0.05)
+	durations.Observe(0.5)
+	durations.Observe(3)
+
+	NewGaugeFunc("test_sessions", "Number of \"sessions\".", func() float64 { return 4 })
+
+	var buf bytes.Buffer
+	if _, err := DefaultRegistry.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 3.55
+test_duration_seconds_count 3
+# HELP test_requests_total Number of requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/articles",status="200"} 2
+test_requests_total{route="/login",status="401"} 2
+# HELP test_sessions Number of "sessions".
+# TYPE test_sessions gauge
+test_sessions 4
+`
+	if buf.String() != expected {
+		t.Errorf("Metrics are\n%s\nshould be\n%s", buf.String(), expected)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Wrong number of label values should panic")
+		}
+	}()
+	requests.Inc("/articles")
+}
+
+func TestFormatLabels(t *testing.T) {
+	labels := formatLabels([]string{"path"}, []string{"a\"b\\c\nd"}, "le", "+Inf")
+	if labels != `{path="a\"b\\c\nd",le="+Inf"}` {
+		t.Errorf("Labels should be escaped: %s", labels)
+	}
+
+	for value, expected := range map[float64]string{0.1: "0.1", 3: "3", math.Inf(1): "+Inf"} {
+		if formatValue(value) != expected {
+			t.Errorf("%v is formatted as %s, should be %s", value, formatValue(value), expected)
+		}
+	}
+}
@@ -2,13 +2,14 @@ package models
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
-	blackfriday "github.com/russross/blackfriday/v2"
-
 	"github.com/BurntSushi/toml"
 )
 
@@ -16,11 +17,18 @@ const (
 	delimiter = "\n+++\n"
 )
 
+// ErrMissingDelimiter is returned when reading an article file which has no "+++" line between its metadata and its
+// content, e.g. because it was created by hand.
+var ErrMissingDelimiter = errors.New("missing +++ line after the metadata")
+
 // Metadata for an article.
 // Extracted from the TOML data at the beginning of an article file.
 type Metadata struct {
 	Title        string
 	LastEditedAt int64
+	// Fields are all other values in the TOML data, e.g. an article's owner.
+	// Their types are the TOML types, e.g. string, int64 or time.Time.
+	Fields map[string]interface{} `toml:"-"`
 }
 
 // Article contains all the relevant data for displaying an article.
@@ -29,6 +37,10 @@ type Article struct {
 	Meta    Metadata
 	parsed  bool
 	Content []byte
+	TOC     *TableOfContents
+	// Includes are the names of the articles included in this one, the article has to be rendered again if
+	// any of them change.
+	Includes []string
 }
 
 // Read the article data from disk and parse the TOML at the beginning of the file.
@@ -38,61 +50,141 @@ func (a *Article) Read() error {
 		return err
 	}
 
+	return a.parse(data)
+}
+
+// parse splits the data of an article file into the metadata and the content.
+func (a *Article) parse(data []byte) error {
 	index := bytes.Index(data, []byte(delimiter))
+	if index == -1 {
+		return ErrMissingDelimiter
+	}
 
 	a.Content = data[index+len(delimiter):]
 
 	metadata := data[:index]
 
-	err = toml.Unmarshal(metadata, &a.Meta)
+	err := toml.Unmarshal(metadata, &a.Meta)
 
 	if err != nil {
 		return err
 	}
 
+	a.Meta.Fields = map[string]interface{}{}
+	if err = toml.Unmarshal(metadata, &a.Meta.Fields); err != nil {
+		return err
+	}
+
+	for key := range a.Meta.Fields {
+		if isReservedField(key) {
+			delete(a.Meta.Fields, key)
+		}
+	}
+
 	a.parsed = true
 	return nil
 }
 
-// ContentHTML converts the article's content from markdown to HTML.
-func (a *Article) ContentHTML() ([]byte, error) {
-	options := blackfriday.WithExtensions(blackfriday.CommonExtensions)
+// Name returns the path of the article inside the wiki, for example "category/article".
+func (a *Article) Name(config *Config) string {
+	rel, err := filepath.Rel(config.ContentPath, a.Path)
+	if err != nil {
+		rel = filepath.Base(a.Path)
+	}
+
+	return filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+}
+
+// Format returns the format the article is written in, which is decided by its file extension.
+// Files with unknown extensions are treated as markdown.
+func (a *Article) Format() *Format {
+	if format := FormatByExtension(filepath.Ext(a.Path)); format != nil {
+		return format
+	}
 
-	output := blackfriday.Run(a.Content, options)
+	return FormatByExtension(DefaultFormat)
+}
 
+// Source returns the article as it is written in the editor, which is its content preceded by the front matter
+// holding its custom fields.
+func (a *Article) Source() []byte {
+	return JoinFrontMatter(a.Meta.Fields, a.Content)
+}
+
+// ContentHTML converts the article's content to HTML using the renderer of the article's format.
+// Also builds the article's table of contents and collects the included articles, which are available as
+// a.TOC and a.Includes afterwards.
+func (a *Article) ContentHTML(config *Config) ([]byte, error) {
+	output, toc, r, err := renderArticle(a.Content, a.Format(), a.Name(config), config)
+	if err != nil {
+		return nil, err
+	}
+
+	a.TOC = toc
+	a.Includes = r.includes
 	return output, nil
 }
 
 // Write the article's content back to disk. Also creates all relevant directories.
-func (a *Article) Write() error {
-	err := os.MkdirAll(filepath.Dir(a.Path), os.ModePerm)
+// The file is replaced at once, so that readers never see a partly written article.
+func (a *Article) Write() (err error) {
+	defer countStorageError("articles", &err)
+
+	err = os.MkdirAll(filepath.Dir(a.Path), dirPerm)
 	if err != nil {
 		return err
 	}
 
-	file, err := os.OpenFile(a.Path, os.O_RDWR|os.O_CREATE, os.ModePerm)
-	if err != nil {
+	defer contentChanged()
+
+	return writeFile(a.Path, filePerm, func(w io.Writer) error {
+		enc := toml.NewEncoder(w)
+
+		if err := enc.Encode(&a.Meta); err != nil {
+			return err
+		}
+
+		if len(a.Meta.Fields) != 0 {
+			if err := enc.Encode(a.Meta.Fields); err != nil {
+				return err
+			}
+		}
+
+		if _, err := w.Write([]byte("\n" + delimiter)); err != nil {
+			return err
+		}
+
+		_, err := w.Write(a.Content)
 		return err
-	}
+	})
+}
 
-	enc := toml.NewEncoder(file)
+// MoveArticle renames the article file at path to newPath, together with its attachments. Also creates the
+// category directories of newPath.
+func MoveArticle(path, newPath string) error {
+	defer contentChanged()
 
-	err = enc.Encode(&a.Meta)
-	if err != nil {
+	if err := os.MkdirAll(filepath.Dir(newPath), dirPerm); err != nil {
 		return err
 	}
 
-	_, err = file.Write([]byte("\n" + delimiter))
-	if err != nil {
+	if err := os.Rename(path, newPath); err != nil {
 		return err
 	}
 
-	_, err = file.Write(a.Content)
-	if err != nil {
+	if err := os.Rename(AttachmentDirectory(path), AttachmentDirectory(newPath)); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	return file.Close()
+	return nil
+}
+
+// RemoveArticleFile removes the article file at path. Its attachments are kept, they are shared with a file of
+// the article in another format.
+func RemoveArticleFile(path string) error {
+	defer contentChanged()
+
+	return os.Remove(path)
 }
 
 // LoadArticle loads the article (contents) at the specified path from disk.
@@ -116,10 +208,11 @@ func LoadArticle(path string) (*Article, error) {
 }
 
 // NewArticle is a convenience function to create a new Article struct.
+// ext is the file extension of the article's format, e.g. ".md".
 // The article's LastEditedAt field will be set to the current time.
-func NewArticle(title, content, dir string) *Article {
+func NewArticle(title, content, dir, ext string) *Article {
 	return &Article{
-		Path:    filepath.Join(dir, title+".md"),
+		Path:    filepath.Join(dir, title+ext),
 		Content: []byte(content),
 		parsed:  true,
 		Meta: Metadata{
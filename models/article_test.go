@@ -1,8 +1,10 @@
 package models
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -55,12 +57,11 @@ This is just some test text
 		t.Errorf(`LastEditedAt is %v, should be %v`, article.Meta.LastEditedAt, now)
 	}
 
-	shouldHTML := `<h1>Test Heading</h1>
-
+	shouldHTML := `<h1 id="test-heading">Test Heading<a class="heading-anchor" href="#test-heading" aria-label="Permalink">#</a></h1>
 <p>This is just some test text</p>
 `
 
-	html, err := article.ContentHTML()
+	html, err := article.ContentHTML(&Config{ContentPath: os.TempDir()})
 	if err != nil {
 		t.Error(err)
 	}
@@ -69,3 +70,37 @@ This is just some test text
 		t.Errorf(`HTML is "%v", should be "%v"`, string(html), shouldHTML)
 	}
 }
+
+func TestOverwriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	article := NewArticle("Foo", strings.Repeat("Long content\n", 100), dir, ".md")
+	if err = article.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	article.Content = []byte("Short\n")
+	if err = article.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	article, err = LoadArticle(article.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(article.Content) != "Short\n" {
+		t.Errorf("Content is %q, the old content should be gone", article.Content)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Mode().Perm() != filePerm {
+		t.Errorf("Only the article should be left with the permission %v, got %v", os.FileMode(filePerm), files)
+	}
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// AttachmentDirectorySuffix is appended to an article's file name (without extension) to get the
+	// directory in which the article's attachments are stored.
+	AttachmentDirectorySuffix = ".attachments"
+	// AttachmentLinkPrefix marks a link or image destination in markdown as reference to an attachment.
+	AttachmentLinkPrefix = "attachment:"
+)
+
+var (
+	attachmentNameRegexp = regexp.MustCompile(`^[\w\d_ -]+\.[\w\d]+$`)
+
+	// ErrAttachmentTooLarge is returned when an attachment exceeds the configured size limit.
+	ErrAttachmentTooLarge = errors.New("Attachment too large")
+	// ErrAttachmentTypeNotAllowed is returned when the attachment's file type is not in the configured allowlist.
+	ErrAttachmentTypeNotAllowed = errors.New("Attachment type not allowed")
+	// ErrInvalidAttachmentName is returned when the attachment's name contains invalid characters.
+	ErrInvalidAttachmentName = errors.New("Invalid attachment name")
+)
+
+// An Attachment is a file which belongs to an article, for example an image.
+type Attachment struct {
+	Name    string
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// ContentType returns the MIME type of the attachment, based on its file extension.
+func (a *Attachment) ContentType() string {
+	if t := mime.TypeByExtension(filepath.Ext(a.Name)); len(t) != 0 {
+		return t
+	}
+
+	return "application/octet-stream"
+}
+
+// IsImage checks if the attachment is an image which can be displayed by browsers.
+func (a *Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType(), "image/")
+}
+
+// AttachmentDirectory returns the directory where the attachments of the article file at path are stored.
+func AttachmentDirectory(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + AttachmentDirectorySuffix
+}
+
+// ValidAttachmentName checks that the name is a plain file name with an extension.
+func ValidAttachmentName(name string) bool {
+	return attachmentNameRegexp.MatchString(name)
+}
+
+// AttachmentAllowed checks if files with the name's extension may be uploaded.
+func (c *Config) AttachmentAllowed(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+
+	for _, t := range c.AttachmentTypes {
+		if t == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadAttachment reads the metadata of the attachment with the name in the directory dir.
+func LoadAttachment(dir, name string) (*Attachment, error) {
+	if !ValidAttachmentName(name) {
+		return nil, ErrInvalidAttachmentName
+	}
+
+	path := filepath.Join(dir, name)
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if stat.IsDir() {
+		return nil, os.ErrNotExist
+	}
+
+	return &Attachment{
+		Name:    name,
+		Path:    path,
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+	}, nil
+}
+
+// ListAttachments returns all attachments in the directory dir, sorted by name.
+// A missing directory is not an error, it simply means there are no attachments.
+func ListAttachments(dir string) ([]*Attachment, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []*Attachment{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	attachments := []*Attachment{}
+
+	for _, file := range files {
+		if file.IsDir() || !ValidAttachmentName(file.Name()) {
+			continue
+		}
+
+		attachments = append(attachments, &Attachment{
+			Name:    file.Name(),
+			Path:    filepath.Join(dir, file.Name()),
+			Size:    file.Size(),
+			ModTime: file.ModTime(),
+		})
+	}
+
+	sort.Slice(attachments, func(i, j int) bool {
+		return attachments[i].Name < attachments[j].Name
+	})
+
+	return attachments, nil
+}
+
+// SaveAttachment writes the data read from r to a new attachment in the directory dir.
+// An existing attachment with the same name will be replaced.
+func SaveAttachment(dir, name string, r io.Reader, config *Config) (*Attachment, error) {
+	if !ValidAttachmentName(name) {
+		return nil, ErrInvalidAttachmentName
+	}
+
+	if !config.AttachmentAllowed(name) {
+		return nil, ErrAttachmentTypeNotAllowed
+	}
+
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, name)
+
+	// Write to a temporary file first, so that an upload which is too large doesn't destroy an existing attachment.
+	tmp, err := ioutil.TempFile(dir, ".upload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.LimitReader(r, config.AttachmentMaxSize+1))
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	if n > config.AttachmentMaxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+	contentChanged()
+
+	return LoadAttachment(dir, name)
+}
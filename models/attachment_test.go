@@ -0,0 +1,50 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveAttachment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "_TestSaveAttachment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{
+		AttachmentMaxSize: 8,
+		AttachmentTypes:   []string{".txt"},
+	}
+
+	attachmentDir := AttachmentDirectory(filepath.Join(dir, "article.md"))
+
+	if _, err = SaveAttachment(attachmentDir, "notes.txt", strings.NewReader("12345678"), config); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = SaveAttachment(attachmentDir, "notes.txt", strings.NewReader("123456789"), config); err != ErrAttachmentTooLarge {
+		t.Errorf("Error is %v, should be %v", err, ErrAttachmentTooLarge)
+	}
+
+	if _, err = SaveAttachment(attachmentDir, "script.sh", strings.NewReader("echo"), config); err != ErrAttachmentTypeNotAllowed {
+		t.Errorf("Error is %v, should be %v", err, ErrAttachmentTypeNotAllowed)
+	}
+
+	if _, err = SaveAttachment(attachmentDir, "../escape.txt", strings.NewReader("echo"), config); err != ErrInvalidAttachmentName {
+		t.Errorf("Error is %v, should be %v", err, ErrInvalidAttachmentName)
+	}
+
+	attachments, err := ListAttachments(attachmentDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed upload must not have replaced the existing attachment.
+	if len(attachments) != 1 || attachments[0].Size != 8 {
+		t.Errorf("Attachments are %v, should only contain notes.txt with 8 bytes", attachments)
+	}
+}
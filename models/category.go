@@ -38,12 +38,23 @@ func (c *Category) ScanEntries() error {
 		return err
 	}
 
-	entries := make([]string, len(files))
+	entries := make([]string, 0, len(files))
 
-	for i, file := range files {
-		// We also remove the extensions here because those are not relevant.
+	for _, file := range files {
 		name := file.Name()
-		entries[i] = strings.Replace(name, filepath.Ext(name), "", -1)
+
+		// Attachments are listed on the article they belong to.
+		if file.IsDir() && strings.HasSuffix(name, AttachmentDirectorySuffix) {
+			continue
+		}
+
+		// Only articles are listed, not other files which may have ended up in the content directory.
+		if !file.IsDir() && !IsArticleFile(name) {
+			continue
+		}
+
+		// We also remove the extensions here because those are not relevant.
+		entries = append(entries, strings.Replace(name, filepath.Ext(name), "", -1))
 	}
 
 	c.Entries = entries
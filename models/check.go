@@ -0,0 +1,263 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// A ProblemKind describes what is wrong with a file found by CheckConsistency.
+type ProblemKind string
+
+// The kinds of problems CheckConsistency finds. The wiki has no redirects, so there are no broken redirects to
+// report. Includes are the references from one article to another which break when the referenced article is
+// moved or deleted, so broken includes are reported in their place.
+const (
+	ProblemUnreadable          ProblemKind = "unreadable file"
+	ProblemFrontMatter         ProblemKind = "malformed front matter"
+	ProblemInvalidTOML         ProblemKind = "invalid TOML"
+	ProblemOrphanedAttachments ProblemKind = "orphaned attachments"
+	ProblemBrokenInclude       ProblemKind = "broken include"
+	ProblemDuplicateEmail      ProblemKind = "duplicate email"
+)
+
+// A Problem is an inconsistency in the wiki's data.
+type Problem struct {
+	Kind ProblemKind
+	// Path is the file or directory with the problem, relative to the data path.
+	Path    string
+	Message string
+	// Fixable problems can be repaired automatically by CheckConsistency, the others have to be fixed by hand.
+	Fixable bool
+	Fixed   bool
+}
+
+func (p *Problem) String() string {
+	s := fmt.Sprintf("%s: %s: %s", p.Path, p.Kind, p.Message)
+	if p.Fixed {
+		s += " (fixed)"
+	}
+
+	return s
+}
+
+// checker collects the problems found in the data directory.
+type checker struct {
+	config   *Config
+	fix      bool
+	problems []*Problem
+}
+
+// add records a problem with the file at path. If fix isn't nil the problem is fixable, and fix is called to
+// repair it if the checker fixes problems.
+func (c *checker) add(kind ProblemKind, path, message string, fix func() error) {
+	rel, err := filepath.Rel(c.config.DataPath, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = path
+	}
+
+	p := &Problem{Kind: kind, Path: filepath.ToSlash(rel), Message: message, Fixable: fix != nil}
+	if fix != nil && c.fix {
+		if err := fix(); err != nil {
+			p.Message += fmt.Sprintf(" (fixing failed: %v)", err)
+		} else {
+			p.Fixed = true
+		}
+	}
+
+	c.problems = append(c.problems, p)
+}
+
+// CheckConsistency scans the content directory and the users for problems, e.g. articles which can't be read.
+// If fix is true the fixable problems are repaired:
+//   - Articles without metadata get metadata with the file name as title, their whole file becomes the content.
+//   - For orphaned attachments an article linking to them is created, so that they can be found in the wiki.
+//
+// All other problems are only reported. The error is only set if the content directory can't be scanned.
+func CheckConsistency(config *Config, userStorage UserStorage, fix bool) ([]*Problem, error) {
+	c := &checker{config: config, fix: fix, problems: []*Problem{}}
+
+	err := filepath.Walk(config.ContentPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == config.ContentPath {
+				return filepath.SkipDir
+			}
+			if path == config.ContentPath {
+				return err
+			}
+
+			c.add(ProblemUnreadable, path, err.Error(), nil)
+			return nil
+		}
+
+		if info.IsDir() {
+			if strings.HasSuffix(info.Name(), AttachmentDirectorySuffix) {
+				c.checkAttachments(path)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if IsArticleFile(info.Name()) {
+			c.checkArticle(path, info)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if userStorage != nil {
+		c.checkUsers(userStorage)
+	}
+
+	return c.problems, nil
+}
+
+// checkArticle checks that the article file at path can be read, and that the articles it includes exist.
+func (c *checker) checkArticle(path string, info os.FileInfo) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		c.add(ProblemUnreadable, path, err.Error(), nil)
+		return
+	}
+
+	article := &Article{Path: path}
+	err = article.parse(data)
+	if errors.Is(err, ErrMissingDelimiter) || (err != nil && HasFrontMatter(data)) {
+		message := err.Error()
+		if HasFrontMatter(data) {
+			message = "front matter as written in the editor instead of metadata"
+		}
+
+		c.add(ProblemFrontMatter, path, message, func() error {
+			return addMetadata(article, data, info)
+		})
+		return
+	} else if err != nil {
+		c.add(ProblemInvalidTOML, path, err.Error(), nil)
+		return
+	}
+
+	names, err := directIncludes(article, c.config)
+	if err != nil {
+		c.add(ProblemUnreadable, path, fmt.Sprintf("can't be rendered: %v", err), nil)
+		return
+	}
+
+	for _, name := range names {
+		if !ValidArticleName(name) {
+			c.add(ProblemBrokenInclude, path, fmt.Sprintf("%q: %s", name, errIncludeDenied), nil)
+		} else if file := statArticle(filepath.Join(c.config.ContentPath, filepath.FromSlash(name))); len(file.Path) == 0 {
+			c.add(ProblemBrokenInclude, path, fmt.Sprintf("%q: %s", name, errIncludeMissing), nil)
+		}
+	}
+}
+
+// addMetadata writes the article file again with metadata. Front matter as written in the editor is turned into
+// the article's fields, everything else becomes the content.
+func addMetadata(article *Article, data []byte, info os.FileInfo) error {
+	fields, content, err := SplitFrontMatter(data)
+	if err != nil {
+		fields, content = map[string]interface{}{}, data
+	}
+
+	article.Content = content
+	article.Meta = Metadata{
+		Title:        strings.TrimSuffix(info.Name(), filepath.Ext(info.Name())),
+		LastEditedAt: info.ModTime().Unix(),
+		Fields:       fields,
+	}
+
+	return article.Write()
+}
+
+// directIncludes returns the names of the articles the article includes, without the articles they include in turn.
+func directIncludes(article *Article, config *Config) ([]string, error) {
+	output, err := article.Format().Renderer.Render(article.Content, article.Name(config), config)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := html.ParseFragment(bytes.NewReader(output), newElement(atom.Div))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if name, _, ok := includeDirective(n); ok {
+			names = append(names, name)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+
+	return names, nil
+}
+
+// checkAttachments checks that the attachment directory at dir belongs to an article.
+func (c *checker) checkAttachments(dir string) {
+	base := strings.TrimSuffix(dir, AttachmentDirectorySuffix)
+	if _, err := FindArticle(base); err == nil {
+		return
+	}
+
+	c.add(ProblemOrphanedAttachments, dir, "there is no article they belong to", func() error {
+		return addAttachmentArticle(base, dir)
+	})
+}
+
+// addAttachmentArticle creates the article at base, the path without extension, which links to the attachments
+// in dir.
+func addAttachmentArticle(base, dir string) error {
+	attachments, err := ListAttachments(dir)
+	if err != nil {
+		return err
+	}
+
+	format := FormatByExtension(DefaultFormat)
+
+	var content strings.Builder
+	content.WriteString("Attachments which were left without an article:\n\n")
+	for _, a := range attachments {
+		fmt.Fprintf(&content, "- %s\n", format.Link(a.Name, AttachmentLinkPrefix+a.Name, false))
+	}
+
+	return NewArticle(filepath.Base(base), content.String(), filepath.Dir(base), format.Extension).Write()
+}
+
+// checkUsers checks that no two users have the same email, which would make logging in ambiguous.
+func (c *checker) checkUsers(userStorage UserStorage) {
+	ids := map[string][]string{}
+	emails := []string{}
+	for _, u := range userStorage.GetUsers() {
+		email := strings.ToLower(u.Email)
+		if _, ok := ids[email]; !ok {
+			emails = append(emails, email)
+		}
+		ids[email] = append(ids[email], fmt.Sprint(u.ID))
+	}
+	sort.Strings(emails)
+
+	for _, email := range emails {
+		if len(ids[email]) > 1 {
+			c.add(ProblemDuplicateEmail, c.config.UserStoragePath, fmt.Sprintf("%s is used by the users %s", email, strings.Join(ids[email], ", ")), nil)
+		}
+	}
+}
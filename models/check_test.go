@@ -0,0 +1,106 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{DataPath: dir, ContentPath: filepath.Join(dir, "content"), UserStoragePath: filepath.Join(dir, "users.db"), BaseURL: "/"}
+
+	if err = NewArticle("good", "Fine\n\n{{include \"other/good\"}}\n", filepath.Join(config.ContentPath, "other"), ".md").Write(); err != nil {
+		t.Fatal(err)
+	}
+	if err = NewArticle("includes", "```\n{{include \"in code\"}}\n```\n\n{{include \"missing\"}}\n", config.ContentPath, ".md").Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"content/plain.md":                  "+++\nowner = \"Jane\"\n+++\nJust content\n",
+		"content/nodelimiter.md":            "Only content\n",
+		"content/broken.md":                 "Title = \"Broken\n\n+++\nContent\n",
+		"content/gone.attachments/file.txt": "attached",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err = LoadArticle(filepath.Join(config.ContentPath, "nodelimiter.md")); err != ErrMissingDelimiter {
+		t.Errorf("Reading an article without delimiter should fail with ErrMissingDelimiter, got %v", err)
+	}
+
+	userStorage, _ := LoadUserStorage(config.UserStoragePath)
+	for _, email := range []string{"jane@example.com", "john@example.com"} {
+		if err = userStorage.AddUser(&User{Email: email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	userStorage.GetUsers()[1].Email = "Jane@example.com"
+
+	expected := map[string]ProblemKind{
+		"content/plain.md":         ProblemFrontMatter,
+		"content/nodelimiter.md":   ProblemFrontMatter,
+		"content/broken.md":        ProblemInvalidTOML,
+		"content/gone.attachments": ProblemOrphanedAttachments,
+		"content/includes.md":      ProblemBrokenInclude,
+		"users.db":                 ProblemDuplicateEmail,
+	}
+
+	for _, fix := range []bool{false, true} {
+		problems, err := CheckConsistency(config, userStorage, fix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(problems) != len(expected) {
+			t.Errorf("Expected %d problems, got %v", len(expected), problems)
+		}
+		for _, p := range problems {
+			if expected[p.Path] != p.Kind {
+				t.Errorf("Unexpected problem %v", p)
+			}
+			if p.Fixed != (fix && p.Fixable) {
+				t.Errorf("Problem %v should only be fixed if it is fixable", p)
+			}
+		}
+	}
+
+	article, err := LoadArticle(filepath.Join(config.ContentPath, "plain.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Meta.Title != "plain" || article.Meta.Fields["owner"] != "Jane" || string(article.Content) != "Just content\n" {
+		t.Errorf("Fixed article has wrong metadata or content: %+v, %q", article.Meta, article.Content)
+	}
+
+	article, err = LoadArticle(filepath.Join(config.ContentPath, "gone.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(article.Content), "[file.txt](attachment:file.txt)") {
+		t.Errorf("Article for orphaned attachments should link to them, got %q", article.Content)
+	}
+
+	problems, err := CheckConsistency(config, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 {
+		t.Errorf("Only the problems which can't be fixed should be left, got %v", problems)
+	}
+}
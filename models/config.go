@@ -1,42 +1,572 @@
 package models
 
 import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/alecthomas/chroma/styles"
 )
 
+// EnvPrefix is the prefix of all environment variables which configure Alexandria.
+const EnvPrefix = "ALEXANDRIA_"
+
 // Config containts all the (global) configuration needed to make Alexandria run.
-// In most cases this struct should be created by using the NewConfig function.
+// In most cases this struct should be created by using the LoadConfig function.
+//
+// Every field with a TOML key can be set in the config file, with an environment variable and with a command line
+// flag, e.g. the key "base_url" with ALEXANDRIA_BASE_URL and --base-url.
 type Config struct {
-	ContentPath       string
-	UserStoragePath   string
-	TemplateDirectory string
-	AssetPath         string
-	Host              string
-	Port              string
-	BaseURL           string
+	DataPath           string   `toml:"data_path" help:"directory where articles, users and revisions are stored"`
+	ContentPath        string   `toml:"-"`
+	UserStoragePath    string   `toml:"-"`
+	WebhookPath        string   `toml:"-"`
+	SessionPath        string   `toml:"-"`
+	RevisionPath       string   `toml:"-"`
+	CachePath          string   `toml:"-"`
+	LockPath           string   `toml:"-"`
+	TemplateDirectory  string   `toml:"template_dir" help:"directory with templates replacing the embedded ones"`
+	AssetPath          string   `toml:"asset_dir" help:"directory with assets replacing the embedded ones"`
+	Host               string   `toml:"host" help:"host on which the HTTP server listens"`
+	Port               string   `toml:"port" help:"port on which the HTTP server listens"`
+	Listen             string   `toml:"listen" help:"unix:<path> or systemd to listen on a Unix socket or an activated socket instead of host and port"`
+	ReadTimeout        duration `toml:"read_timeout" help:"maximum duration for reading a request, e.g. 30s"`
+	WriteTimeout       duration `toml:"write_timeout" help:"maximum duration for writing a response"`
+	IdleTimeout        duration `toml:"idle_timeout" help:"maximum duration keep-alive connections are kept open between requests"`
+	ShutdownTimeout    duration `toml:"shutdown_timeout" help:"maximum duration to wait for running requests when shutting down"`
+	BaseURL            string   `toml:"base_url" help:"URL under which the wiki is reachable"`
+	BasePath           string   `toml:"-"`
+	AttachmentMaxSize  int64    `toml:"attachment_max_size" help:"maximum size of attachments in bytes"`
+	AttachmentTypes    []string `toml:"attachment_types" help:"comma separated file extensions which may be uploaded"`
+	HighlightStyle     string   `toml:"highlight_style" help:"chroma style used for code blocks"`
+	MarkdownExtensions []string `toml:"markdown_extensions" help:"comma separated list of enabled markdown extensions"`
+	TicketURL          string   `toml:"ticket_url" help:"URL ticket IDs are linked to, {id} is replaced by the ID"`
+	TicketPrefixes     []string `toml:"ticket_prefixes" help:"comma separated list of ticket ID prefixes"`
+	ArticleTemplates   string   `toml:"article_templates" help:"category holding the templates for new articles"`
+	DevMode            bool     `toml:"dev_mode" help:"parse templates on every request"`
+	TLSCert            string   `toml:"tls_cert" help:"PEM certificate file, enables HTTPS together with tls_key"`
+	TLSKey             string   `toml:"tls_key" help:"PEM private key file of the certificate"`
+	HTTPRedirectPort   string   `toml:"http_redirect_port" help:"port on which HTTP requests are redirected to HTTPS"`
+	HSTSMaxAge         int64    `toml:"hsts_max_age" help:"seconds browsers should only use HTTPS, 0 disables HSTS"`
+	LogFormat          string   `toml:"log_format" help:"format of log lines, text (logfmt) or json"`
+	LogLevel           string   `toml:"log_level" help:"minimum level of logged messages: debug, info, warn or error"`
+	LogFile            string   `toml:"log_file" help:"file log lines are appended to instead of stderr"`
+	MetricsToken       string   `toml:"metrics_token" help:"bearer token required to read /metrics"`
+	MetricsAddress     string   `toml:"metrics_address" help:"address of a separate listener serving /metrics, e.g. localhost:9100"`
+	TrustedProxies     []string `toml:"trusted_proxies" help:"comma separated IPs or CIDRs of reverse proxies whose X-Forwarded-Proto is trusted"`
+
+	sections map[string]ConfigSection
+}
+
+// A ConfigSection configures a feature in its own table of the config file, e.g. [mail], so that features can be
+// configured without adding fields to Config. Its fields are set like the fields of Config, with the section's
+// name as prefix of environment variables, e.g. ALEXANDRIA_MAIL_HOST for the key "host" of the section "mail".
+// Sections can't be set with command line flags.
+type ConfigSection interface {
+	// Validate checks the section's values after they were loaded.
+	Validate() error
+}
+
+var configSections = map[string]func() ConfigSection{}
+
+// RegisterConfigSection adds a section with the name to the config. newSection returns a pointer to a struct
+// holding the section's default values.
+func RegisterConfigSection(name string, newSection func() ConfigSection) {
+	configSections[name] = newSection
+}
+
+// Section returns the section with the name, or nil if no section with the name is registered.
+func (c *Config) Section(name string) ConfigSection {
+	return c.sections[name]
+}
+
+// DefaultConfig returns the config used if nothing else is configured.
+func DefaultConfig() *Config {
+	config := &Config{
+		DataPath:           "data",
+		Host:               "localhost",
+		Port:               ":8080",
+		ReadTimeout:        duration(30 * time.Second),
+		WriteTimeout:       duration(60 * time.Second),
+		IdleTimeout:        duration(2 * time.Minute),
+		ShutdownTimeout:    duration(30 * time.Second),
+		BaseURL:            "http://localhost:8080/",
+		AttachmentMaxSize:  10 * 1024 * 1024,
+		AttachmentTypes:    []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".pdf", ".txt", ".csv", ".zip"},
+		HighlightStyle:     "github",
+		MarkdownExtensions: []string{"table", "strikethrough", "linkify", "tasklist", "definitionlist", "footnote", "typographer", "callouts", "containers", "tickets"},
+		TicketPrefixes:     []string{},
+		ArticleTemplates:   "templates",
+		HSTSMaxAge:         365 * 24 * 60 * 60,
+		LogFormat:          "text",
+		LogLevel:           "info",
+		TrustedProxies:     []string{},
+		sections:           map[string]ConfigSection{},
+	}
+
+	for name, newSection := range configSections {
+		config.sections[name] = newSection()
+	}
+
+	return config
+}
+
+// A duration is a time.Duration which is written like "30s" or "2m" in the config file, environment variables
+// and flags.
+type duration time.Duration
+
+func (d *duration) UnmarshalText(text []byte) error {
+	val, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("%q is not a duration, e.g. 30s", text)
+	}
+
+	*d = duration(val)
+	return nil
+}
+
+// Duration returns the duration as a time.Duration.
+func (d duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// configKey returns the TOML key of the struct field, or an empty string if the field can't be configured.
+func configKey(field reflect.StructField) string {
+	if len(field.PkgPath) != 0 {
+		return ""
+	}
+
+	key := field.Tag.Get("toml")
+	if key == "-" {
+		return ""
+	} else if len(key) == 0 {
+		key = strings.ToLower(field.Name)
+	}
+
+	return key
+}
+
+// setConfigValue parses the text and assigns it to the field. Lists are comma separated.
+func setConfigValue(field reflect.Value, text string) error {
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(text))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		val, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", text)
+		}
+		field.SetBool(val)
+	case reflect.Int, reflect.Int64:
+		val, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", text)
+		}
+		field.SetInt(val)
+	case reflect.Slice:
+		list := []string{}
+		for _, val := range strings.Split(text, ",") {
+			if val = strings.TrimSpace(val); len(val) != 0 {
+				list = append(list, val)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("fields of type %v can't be configured", field.Type())
+	}
+
+	return nil
+}
+
+// applyEnv sets all fields of the struct v points to for which an environment variable with the prefix is set.
+func applyEnv(v interface{}, prefix string) error {
+	errs := []error{}
+	value := reflect.ValueOf(v).Elem()
+
+	for i := 0; i < value.NumField(); i++ {
+		key := configKey(value.Type().Field(i))
+		if len(key) == 0 {
+			continue
+		}
+
+		name := prefix + strings.ToUpper(key)
+		if text, ok := os.LookupEnv(name); ok {
+			if err := setConfigValue(value.Field(i), text); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// loadFile reads the config file at path into the config and its sections.
+// Keys which don't belong to the config or any section are an error, as they are most likely misspelled.
+func (c *Config) loadFile(path string) error {
+	md, err := toml.DecodeFile(path, c)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	tables := map[string]toml.Primitive{}
+	sectionsMD, err := toml.DecodeFile(path, &tables)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	for name, section := range c.sections {
+		if table, ok := tables[name]; ok {
+			if err = sectionsMD.PrimitiveDecode(table, section); err != nil {
+				return fmt.Errorf("%s: [%s]: %v", path, name, err)
+			}
+		}
+	}
+
+	// Keys are only unknown if neither the config nor the section they belong to decoded them.
+	undecodedBySection := map[string]bool{}
+	for _, key := range sectionsMD.Undecoded() {
+		undecodedBySection[key.String()] = true
+	}
+
+	unknown := []string{}
+	for _, key := range md.Undecoded() {
+		if _, ok := c.sections[key[0]]; !ok || undecodedBySection[key.String()] {
+			unknown = append(unknown, key.String())
+		}
+	}
+
+	if len(unknown) != 0 {
+		return fmt.Errorf("%s: unknown keys %s", path, strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// LoadConfig builds the config from, in increasing priority, the defaults, the config file, environment variables
+// and the command line flags in args. The config file is set with --config or ALEXANDRIA_CONFIG.
+// Returns the arguments which remain after the flags.
+func LoadConfig(args []string) (*Config, []string, error) {
+	config := DefaultConfig()
+
+	flags := flag.NewFlagSet("alexandria", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv(EnvPrefix+"CONFIG"), "TOML config file")
+
+	// Flags are applied once the config file and environment variables are loaded, so that they win.
+	setFlags := []func() error{}
+	value := reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key := configKey(field)
+		if len(key) == 0 {
+			continue
+		}
+
+		name := strings.Replace(key, "_", "-", -1)
+		fieldValue := value.Field(i)
+		flags.Func(name, field.Tag.Get("help"), func(text string) error {
+			setFlags = append(setFlags, func() error {
+				if err := setConfigValue(fieldValue, text); err != nil {
+					return fmt.Errorf("--%s: %v", name, err)
+				}
+				return nil
+			})
+			return nil
+		})
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if len(*configPath) != 0 {
+		if err := config.loadFile(*configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	errs := []error{applyEnv(config, EnvPrefix)}
+	for name, section := range config.sections {
+		errs = append(errs, applyEnv(section, EnvPrefix+strings.ToUpper(name)+"_"))
+	}
+	for _, set := range setFlags {
+		errs = append(errs, set())
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+
+	config.normalize()
+
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return config, flags.Args(), nil
+}
+
+// normalize brings values into the form the rest of the wiki expects and sets the paths inside the data path.
+func (c *Config) normalize() {
+	c.ContentPath = filepath.Join(c.DataPath, "content")
+	c.UserStoragePath = filepath.Join(c.DataPath, "users.db")
+	c.WebhookPath = filepath.Join(c.DataPath, "webhooks.db")
+	c.SessionPath = filepath.Join(c.DataPath, "sessions.db")
+	c.RevisionPath = filepath.Join(c.DataPath, "revisions")
+	c.CachePath = filepath.Join(c.DataPath, "cache")
+	c.LockPath = filepath.Join(c.DataPath, "alexandria.lock")
+
+	if len(c.Port) != 0 && !strings.HasPrefix(c.Port, ":") {
+		c.Port = ":" + c.Port
+	}
+
+	if len(c.HTTPRedirectPort) != 0 && !strings.HasPrefix(c.HTTPRedirectPort, ":") {
+		c.HTTPRedirectPort = ":" + c.HTTPRedirectPort
+	}
+
+	if len(c.BaseURL) != 0 && !strings.HasSuffix(c.BaseURL, "/") {
+		c.BaseURL += "/"
+	}
+
+	c.BasePath = "/"
+	if u, err := url.Parse(c.BaseURL); err == nil && len(u.Path) != 0 {
+		c.BasePath = "/" + strings.TrimPrefix(u.Path, "/")
+	}
+
+	for i, ext := range c.AttachmentTypes {
+		c.AttachmentTypes[i] = strings.ToLower(ext)
+	}
+
+	for i, name := range c.MarkdownExtensions {
+		c.MarkdownExtensions[i] = strings.ToLower(name)
+	}
+
+	c.ArticleTemplates = strings.Trim(c.ArticleTemplates, "/ ")
+}
+
+// Validate checks all values of the config and its sections. All problems are returned together.
+func (c *Config) Validate() error {
+	errs := []error{}
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if len(c.DataPath) == 0 {
+		invalid("data_path", "must not be empty")
+	}
+
+	for key, dir := range map[string]string{"template_dir": c.TemplateDirectory, "asset_dir": c.AssetPath} {
+		if len(dir) == 0 {
+			continue
+		}
+		if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
+			invalid(key, "%q is not a directory", dir)
+		}
+	}
+
+	if !validPort(c.Port) {
+		invalid("port", "%q must be a number between 1 and 65535, e.g. 8080", c.Port)
+	}
+
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		invalid("base_url", "%q must be an absolute http or https URL, e.g. https://wiki.example.com/", c.BaseURL)
+	}
+
+	if c.AttachmentMaxSize <= 0 {
+		invalid("attachment_max_size", "must be greater than 0")
+	}
+
+	for _, ext := range c.AttachmentTypes {
+		if !strings.HasPrefix(ext, ".") || len(ext) == 1 {
+			invalid("attachment_types", "%q must be a file extension starting with a dot, e.g. .png", ext)
+		}
+	}
+
+	if _, ok := styles.Registry[c.HighlightStyle]; !ok {
+		names := make([]string, 0, len(styles.Registry))
+		for name := range styles.Registry {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		invalid("highlight_style", "unknown style %q, available are %s", c.HighlightStyle, strings.Join(names, ", "))
+	}
+
+	for _, name := range c.MarkdownExtensions {
+		markdownExtensions.RLock()
+		_, ok := markdownExtensions.byName[name]
+		markdownExtensions.RUnlock()
+		if !ok {
+			invalid("markdown_extensions", "unknown extension %q", name)
+		}
+	}
+
+	if len(c.TicketURL) != 0 && !strings.Contains(c.TicketURL, "{id}") {
+		invalid("ticket_url", "%q must contain {id}, which is replaced by the ticket ID", c.TicketURL)
+	}
+
+	if len(c.ArticleTemplates) != 0 && !ValidArticleName(c.ArticleTemplates) {
+		invalid("article_templates", "%q is not a valid category", c.ArticleTemplates)
+	}
+
+	if (len(c.TLSCert) == 0) != (len(c.TLSKey) == 0) {
+		invalid("tls_cert", "tls_cert and tls_key must be set together")
+	}
+
+	for key, file := range map[string]string{"tls_cert": c.TLSCert, "tls_key": c.TLSKey} {
+		if len(file) == 0 {
+			continue
+		}
+		if stat, err := os.Stat(file); err != nil || stat.IsDir() {
+			invalid(key, "%q is not a file", file)
+		}
+	}
+
+	if len(c.HTTPRedirectPort) != 0 {
+		if !c.TLSEnabled() {
+			invalid("http_redirect_port", "requires tls_cert and tls_key")
+		} else if !validPort(c.HTTPRedirectPort) || c.HTTPRedirectPort == c.Port {
+			invalid("http_redirect_port", "%q must be a number between 1 and 65535 other than port, e.g. 80", c.HTTPRedirectPort)
+		}
+	}
+
+	if len(c.Listen) != 0 && c.Listen != "systemd" && (!strings.HasPrefix(c.Listen, "unix:") || len(c.Listen) == len("unix:")) {
+		invalid("listen", "%q must be unix:<path> or systemd", c.Listen)
+	}
+
+	for key, timeout := range map[string]duration{"read_timeout": c.ReadTimeout, "write_timeout": c.WriteTimeout, "idle_timeout": c.IdleTimeout, "shutdown_timeout": c.ShutdownTimeout} {
+		if timeout < 0 {
+			invalid(key, "must not be negative")
+		}
+	}
+
+	if c.HSTSMaxAge < 0 {
+		invalid("hsts_max_age", "must not be negative")
+	}
+
+	if format := strings.ToLower(c.LogFormat); format != "text" && format != "json" {
+		invalid("log_format", "%q must be text or json", c.LogFormat)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		invalid("log_level", "%q must be debug, info, warn or error", c.LogLevel)
+	}
+
+	if len(c.MetricsAddress) != 0 {
+		if _, port, err := net.SplitHostPort(c.MetricsAddress); err != nil || !validPort(port) {
+			invalid("metrics_address", "%q must be a host and port, e.g. localhost:9100", c.MetricsAddress)
+		}
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if parseProxy(proxy) == nil {
+			invalid("trusted_proxies", "%q must be an IP address or a CIDR, e.g. 10.0.0.0/8", proxy)
+		}
+	}
+
+	names := make([]string, 0, len(c.sections))
+	for name := range c.sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := c.sections[name].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("[%s]: %v", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(strings.TrimPrefix(port, ":"))
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// parseProxy parses an IP address or a CIDR into a network, or returns nil if it is neither.
+func parseProxy(proxy string) *net.IPNet {
+	if _, network, err := net.ParseCIDR(proxy); err == nil {
+		return network
+	}
+
+	ip := net.ParseIP(proxy)
+	if ip == nil {
+		return nil
+	}
+
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+}
+
+// URLPath returns the absolute path of the wiki page at p, which is relative to the wiki's root, e.g. "/login"
+// becomes "/wiki/login" if the wiki is served under https://example.com/wiki/.
+func (c *Config) URLPath(p string) string {
+	if len(c.BasePath) == 0 {
+		return p
+	}
+
+	return c.BasePath + strings.TrimPrefix(p, "/")
+}
+
+// TLSEnabled reports whether the server should serve HTTPS itself.
+func (c *Config) TLSEnabled() bool {
+	return len(c.TLSCert) != 0 && len(c.TLSKey) != 0
 }
 
-func getEnvVar(n, def string) string {
-	val, ok := os.LookupEnv(n)
-	if ok {
-		return val
+// FromTrustedProxy reports whether the request was sent by one of the TrustedProxies, so that the headers
+// they set can be trusted.
+func (c *Config) FromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
 	}
 
-	return def
+	for _, proxy := range c.TrustedProxies {
+		if network := parseProxy(proxy); network != nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
 }
 
-// NewConfig reads from environment variables to construct the Config object.
-// If an environment variable is not defined a default value will be used instead.
-func NewConfig() *Config {
-	dataPath := getEnvVar("ALEXANDRIA_DATA_PATH", "data")
-	return &Config{
-		ContentPath:       filepath.Join(dataPath, "content"),
-		UserStoragePath:   filepath.Join(dataPath, "users.db"),
-		TemplateDirectory: getEnvVar("ALEXANDRIA_TEMPLATE_DIR", "view/templates"),
-		AssetPath:         getEnvVar("ALEXANDRIA_ASSET_DIR", "assets/public"),
-		Host:              getEnvVar("ALEXANDRIA_HOST", "localhost"),
-		Port:              getEnvVar("ALEXANDRIA_PORT", ":8080"),
-		BaseURL:           getEnvVar("ALEXANDRIA_BASE_URL", "http://localhost:8080/"),
+// IsHTTPS reports whether the request was made over HTTPS, either directly or through one of the
+// TrustedProxies which sets X-Forwarded-Proto. The header is ignored for all other clients, as they could
+// set it to anything.
+func (c *Config) IsHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
 	}
+
+	if !c.FromTrustedProxy(r) {
+		return false
+	}
+
+	return strings.EqualFold(strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0]), "https")
 }
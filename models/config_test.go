@@ -0,0 +1,170 @@
+package models
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfigSection struct {
+	Host string `toml:"host"`
+	Port int64  `toml:"port"`
+}
+
+func (s *testConfigSection) Validate() error {
+	if s.Port < 0 {
+		return errors.New("port: must not be negative")
+	}
+	return nil
+}
+
+func TestLoadConfig(t *testing.T) {
+	RegisterConfigSection("testmail", func() ConfigSection { return &testConfigSection{Port: 25} })
+
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alexandria.toml")
+	content := "data_path = \"/srv/wiki\"\nport = \"9000\"\nbase_url = \"https://wiki.example.com\"\nhost = \"file\"\n" +
+		"attachment_types = [\".PNG\"]\n\n[testmail]\nhost = \"mail.example.com\"\n"
+	if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("ALEXANDRIA_HOST", "env")
+	os.Setenv("ALEXANDRIA_HIGHLIGHT_STYLE", "monokai")
+	os.Setenv("ALEXANDRIA_TESTMAIL_PORT", "587")
+	defer os.Unsetenv("ALEXANDRIA_HOST")
+	defer os.Unsetenv("ALEXANDRIA_HIGHLIGHT_STYLE")
+	defer os.Unsetenv("ALEXANDRIA_TESTMAIL_PORT")
+
+	config, args, err := LoadConfig([]string{"--config", path, "--highlight-style", "vim", "--dev-mode", "true", "--read-timeout", "5s", "rest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(args) != 1 || args[0] != "rest" {
+		t.Errorf("Remaining arguments are %v, should be [rest]", args)
+	}
+
+	if config.ContentPath != filepath.Join("/srv/wiki", "content") || config.Port != ":9000" || config.BaseURL != "https://wiki.example.com/" {
+		t.Errorf("Values from the config file weren't applied: %+v", config)
+	}
+
+	if config.Host != "env" || config.HighlightStyle != "vim" || !config.DevMode {
+		t.Errorf("Environment variables should override the file and flags the environment: %+v", config)
+	}
+
+	if config.ReadTimeout.Duration() != 5*time.Second || config.ShutdownTimeout.Duration() != 30*time.Second {
+		t.Errorf("Timeouts are %v and %v", config.ReadTimeout.Duration(), config.ShutdownTimeout.Duration())
+	}
+
+	if strings.Join(config.AttachmentTypes, ",") != ".png" || config.AttachmentMaxSize != 10*1024*1024 {
+		t.Errorf("Attachment settings are %v %v", config.AttachmentTypes, config.AttachmentMaxSize)
+	}
+
+	section, ok := config.Section("testmail").(*testConfigSection)
+	if !ok || section.Host != "mail.example.com" || section.Port != 587 {
+		t.Errorf("Section wasn't loaded from the file and environment: %+v", section)
+	}
+
+	if err = ioutil.WriteFile(path, []byte("prot = 80\n[testmail]\nhots = \"x\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = LoadConfig([]string{"--config", path})
+	if err == nil || !strings.Contains(err.Error(), "prot") || !strings.Contains(err.Error(), "testmail.hots") {
+		t.Errorf("Unknown keys should be an error, got %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	RegisterConfigSection("testmail", func() ConfigSection { return &testConfigSection{Port: 25} })
+
+	config := DefaultConfig()
+	if err := config.Validate(); err != nil {
+		t.Errorf("Default config should be valid, got %v", err)
+	}
+
+	config.Port = ":99999"
+	config.BaseURL = "wiki.example.com"
+	config.AttachmentTypes = []string{"png"}
+	config.HighlightStyle = "nope"
+	config.MarkdownExtensions = []string{"emoji"}
+	config.TicketURL = "https://jira.example.com/"
+	config.TLSCert = "cert.pem"
+	config.HTTPRedirectPort = ":80"
+	config.TrustedProxies = []string{"proxy"}
+	config.Listen = "tcp:8080"
+	config.Section("testmail").(*testConfigSection).Port = -1
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Config should be invalid")
+	}
+
+	for _, key := range []string{"port", "base_url", "attachment_types", "highlight_style", "markdown_extensions", "ticket_url", "tls_cert", "http_redirect_port", "trusted_proxies", "listen", "[testmail]"} {
+		if !strings.Contains(err.Error(), key+": ") {
+			t.Errorf("Error should mention %v: %v", key, err)
+		}
+	}
+}
+
+func TestConfigIsHTTPS(t *testing.T) {
+	config := &Config{TrustedProxies: []string{"10.0.0.0/8", "::1"}}
+
+	tests := []struct {
+		remoteAddr string
+		proto      string
+		tls        bool
+		expected   bool
+	}{
+		{"192.0.2.1:1234", "", false, false},
+		{"192.0.2.1:1234", "", true, true},
+		{"192.0.2.1:1234", "https", false, false},
+		{"10.1.2.3:1234", "https", false, true},
+		{"10.1.2.3:1234", "http", false, false},
+		{"[::1]:1234", "HTTPS, http", false, true},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if len(test.proto) != 0 {
+			r.Header.Set("X-Forwarded-Proto", test.proto)
+		}
+		if test.tls {
+			r.TLS = &tls.ConnectionState{}
+		} else {
+			r.TLS = nil
+		}
+
+		if config.IsHTTPS(r) != test.expected {
+			t.Errorf("IsHTTPS for %v with X-Forwarded-Proto %q should be %v", test.remoteAddr, test.proto, test.expected)
+		}
+	}
+}
+
+func TestConfigURLPath(t *testing.T) {
+	for baseURL, expected := range map[string]string{
+		"http://localhost:8080":      "/login",
+		"https://intranet/wiki/":     "/wiki/login",
+		"https://intranet/team/wiki": "/team/wiki/login",
+	} {
+		config := &Config{BaseURL: baseURL}
+		config.normalize()
+
+		if path := config.URLPath("/login"); path != expected {
+			t.Errorf("URLPath for %v is %v, should be %v", baseURL, path, expected)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// filePerm is the permission of articles and other content which the web server may serve directly.
+	filePerm = 0644
+	// privateFilePerm is the permission of databases holding secrets, e.g. password hashes.
+	privateFilePerm = 0600
+	// dirPerm is the permission of the content directories, which hold files with filePerm.
+	dirPerm = 0755
+	// privateDirPerm is the permission of directories which hold files with privateFilePerm, e.g. the data
+	// directory with the databases.
+	privateDirPerm = 0700
+)
+
+// writeFile replaces the file at path with the data written by write. The data is written to a temporary file
+// in the same directory, which is synced to disk and then renamed to path, so that a crash or a full disk
+// leaves either the old or the new file, never a mix of both.
+func writeFile(path string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	dir, name := filepath.Split(path)
+
+	// The temporary file doesn't have the extension of an article, so it is ignored if it is left over.
+	tmp, err := ioutil.TempFile(dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+
+	if err = write(tmp); err != nil {
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes the renaming of a file in dir durable. Not all systems can sync directories, so it is only
+// attempted.
+func syncDir(dir string) {
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
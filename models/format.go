@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DefaultFormat is the extension of the format used for articles which don't specify one.
+const DefaultFormat = ".md"
+
+// ErrUnknownFormat is returned if there is no renderer for an article's file extension.
+var ErrUnknownFormat = errors.New("unknown article format")
+
+// A Renderer converts content written in a markup format to HTML.
+// The HTML doesn't need to be safe, it is sanitized before it is displayed.
+// name is the path of the article inside the wiki.
+type Renderer interface {
+	Render(content []byte, name string, config *Config) ([]byte, error)
+}
+
+// RendererFunc allows using a plain function as a Renderer.
+type RendererFunc func(content []byte, name string, config *Config) ([]byte, error)
+
+// Render calls f.
+func (f RendererFunc) Render(content []byte, name string, config *Config) ([]byte, error) {
+	return f(content, name, config)
+}
+
+// A Format is a markup language articles can be written in.
+// Articles are stored with the format's file extension, which decides how they are rendered.
+type Format struct {
+	Name      string
+	Extension string
+	Renderer  Renderer
+	// Link returns the format's syntax for a link to dest, or for displaying dest if image is true.
+	Link func(text, dest string, image bool) string
+}
+
+var formats = struct {
+	sync.RWMutex
+	byExtension map[string]*Format
+}{byExtension: map[string]*Format{}}
+
+// RegisterFormat makes the format available for articles.
+// Registering a format for an extension which is already in use replaces the existing format.
+func RegisterFormat(format *Format) {
+	formats.Lock()
+	defer formats.Unlock()
+	formats.byExtension[format.Extension] = format
+}
+
+// Formats returns all registered formats, sorted by name.
+func Formats() []*Format {
+	formats.RLock()
+	defer formats.RUnlock()
+
+	result := make([]*Format, 0, len(formats.byExtension))
+	for _, format := range formats.byExtension {
+		result = append(result, format)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+}
+
+// FormatByExtension returns the format with the file extension, e.g. ".md", or nil if there is none.
+func FormatByExtension(ext string) *Format {
+	formats.RLock()
+	defer formats.RUnlock()
+	return formats.byExtension[ext]
+}
+
+// FindArticle returns the path of the article file at basePath, which is the article's path without extension.
+// If there is no article in any of the registered formats an error satisfying os.IsNotExist is returned.
+func FindArticle(basePath string) (string, error) {
+	for _, format := range Formats() {
+		path := basePath + format.Extension
+		if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+			return path, nil
+		}
+	}
+
+	return "", &os.PathError{Op: "find", Path: basePath, Err: os.ErrNotExist}
+}
+
+// IsArticleFile checks if the file name has the extension of a registered format.
+func IsArticleFile(name string) bool {
+	return FormatByExtension(filepath.Ext(name)) != nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindArticle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = NewArticle("notes", "* Notes", dir, ".org").Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := FindArticle(filepath.Join(dir, "notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != filepath.Join(dir, "notes.org") {
+		t.Errorf("Path is %v, should be %v", path, filepath.Join(dir, "notes.org"))
+	}
+
+	article, err := LoadArticle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Format().Name != "Org-mode" {
+		t.Errorf("Format is %v, should be Org-mode", article.Format().Name)
+	}
+
+	if _, err = FindArticle(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("Missing article should return a not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// FrontMatterDelimiter is put on the lines before and after the front matter at the beginning of an article's source.
+const FrontMatterDelimiter = "+++"
+
+// reservedFields are set by the wiki itself and can't be used as custom fields.
+var reservedFields = []string{"Title", "LastEditedAt"}
+
+// isReservedField checks if the key is one of the reservedFields. TOML keys are matched case insensitively.
+func isReservedField(key string) bool {
+	for _, field := range reservedFields {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasFrontMatter checks if source starts with front matter.
+func HasFrontMatter(source []byte) bool {
+	return bytes.HasPrefix(source, []byte(FrontMatterDelimiter+"\n"))
+}
+
+// SplitFrontMatter separates the front matter from the content in an article's source, as written in the editor, e.g.
+//
+//	+++
+//	owner = "Jane"
+//	+++
+//	Content
+//
+// The front matter is TOML and becomes the article's custom fields. Reserved fields are ignored.
+// If source has no front matter the fields are empty.
+func SplitFrontMatter(source []byte) (map[string]interface{}, []byte, error) {
+	fields := map[string]interface{}{}
+
+	if !HasFrontMatter(source) {
+		return fields, source, nil
+	}
+
+	rest := source[len(FrontMatterDelimiter)+1:]
+
+	var frontMatter, content []byte
+	if bytes.HasPrefix(rest, []byte(FrontMatterDelimiter+"\n")) || bytes.Equal(rest, []byte(FrontMatterDelimiter)) {
+		content = rest[len(FrontMatterDelimiter):]
+	} else if index := bytes.Index(rest, []byte("\n"+FrontMatterDelimiter+"\n")); index != -1 {
+		frontMatter, content = rest[:index], rest[index+len(FrontMatterDelimiter)+2:]
+	} else if bytes.HasSuffix(rest, []byte("\n"+FrontMatterDelimiter)) {
+		frontMatter = rest[:len(rest)-len(FrontMatterDelimiter)-1]
+	} else {
+		return fields, source, nil
+	}
+
+	if err := toml.Unmarshal(frontMatter, &fields); err != nil {
+		return nil, nil, err
+	}
+
+	for key := range fields {
+		if isReservedField(key) {
+			delete(fields, key)
+		}
+	}
+
+	return fields, bytes.TrimLeft(content, "\n"), nil
+}
+
+// JoinFrontMatter is the opposite of SplitFrontMatter, it returns the source of an article with the fields and content.
+func JoinFrontMatter(fields map[string]interface{}, content []byte) []byte {
+	if len(fields) == 0 {
+		return content
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(FrontMatterDelimiter + "\n")
+	if err := toml.NewEncoder(&buf).Encode(fields); err != nil {
+		return content
+	}
+	buf.WriteString(FrontMatterDelimiter + "\n\n")
+	buf.Write(content)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFrontMatter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := "+++\nowner = \"Jane\"\nreview_by = 2024-06-01\nTitle = \"Ignored\"\n+++\n\n# Content\n"
+
+	fields, content, err := SplitFrontMatter([]byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "# Content\n" {
+		t.Errorf("Content is %q, should be %q", content, "# Content\n")
+	}
+
+	if len(fields) != 2 || fields["owner"] != "Jane" {
+		t.Errorf("Fields should be owner and review_by, got %v", fields)
+	}
+
+	article := NewArticle("service", string(content), dir, ".md")
+	article.Meta.Fields = fields
+	if err = article.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	article, err = LoadArticle(filepath.Join(dir, "service.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Meta.Title != "service" {
+		t.Errorf("Title is %v, should be service", article.Meta.Title)
+	}
+
+	if date, ok := article.Meta.Fields["review_by"].(time.Time); !ok || FormatFieldValue(date) != "2024-06-01" {
+		t.Errorf("review_by should be read as date, got %#v", article.Meta.Fields["review_by"])
+	}
+
+	expected := "+++\nowner = \"Jane\"\nreview_by = 2024-06-01\n+++\n\n# Content\n"
+	if string(article.Source()) != expected {
+		t.Errorf("Source is %q, should be %q", article.Source(), expected)
+	}
+
+	if _, _, err = SplitFrontMatter([]byte("+++\nowner = \n+++\nContent")); err == nil {
+		t.Error("Invalid front matter should return an error")
+	}
+}
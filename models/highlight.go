@@ -0,0 +1,137 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+)
+
+// codeOptions are the options of a fenced code block, which are read from its info string.
+// The info string starts with the language, optionally followed by "linenos" to number the lines
+// and by ranges of lines to highlight, e.g. "```go linenos {3,5-7}".
+type codeOptions struct {
+	Language    string
+	LineNumbers bool
+	Highlight   [][2]int
+}
+
+// parseCodeInfo reads the options from the info string of a fenced code block.
+// Unknown options are ignored, so that info strings meant for other tools don't break rendering.
+func parseCodeInfo(info string) codeOptions {
+	options := codeOptions{Highlight: [][2]int{}}
+
+	// Ranges are taken out before the info string is split, as they may contain spaces, e.g. "{3, 5-7}".
+	for {
+		start := strings.Index(info, "{")
+		end := strings.Index(info, "}")
+		if start == -1 || end < start {
+			break
+		}
+
+		options.Highlight = append(options.Highlight, parseLineRanges(info[start+1:end])...)
+		info = info[:start] + " " + info[end+1:]
+	}
+
+	for i, field := range strings.Fields(info) {
+		switch {
+		case field == "linenos":
+			options.LineNumbers = true
+		case i == 0:
+			options.Language = field
+		}
+	}
+
+	return options
+}
+
+// parseLineRanges parses a comma separated list of line numbers and ranges, e.g. "3,5-7".
+func parseLineRanges(s string) [][2]int {
+	ranges := [][2]int{}
+
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil || start < 1 {
+			continue
+		}
+
+		end := start
+		if len(bounds) == 2 {
+			end, err = strconv.Atoi(bounds[1])
+			if err != nil || end < start {
+				continue
+			}
+		}
+
+		ranges = append(ranges, [2]int{start, end})
+	}
+
+	return ranges
+}
+
+// highlightFormatter returns the formatter for code blocks with the given options.
+// Tokens are marked with CSS classes only, the colours are defined in the stylesheet.
+// Line numbers are put into a separate table column, so that copying the code doesn't copy them too.
+func highlightFormatter(options codeOptions) *chromahtml.Formatter {
+	return chromahtml.New(
+		chromahtml.WithClasses(true),
+		chromahtml.TabWidth(4),
+		chromahtml.WithLineNumbers(options.LineNumbers),
+		chromahtml.LineNumbersInTable(true),
+		chromahtml.HighlightLines(options.Highlight),
+	)
+}
+
+// highlightCode writes the code as syntax highlighted HTML.
+// Code in unknown languages is written without highlighting, but with the same layout.
+// Nothing is written if highlighting fails.
+func highlightCode(w io.Writer, code []byte, info string) error {
+	options := parseCodeInfo(info)
+
+	// Queries aren't highlighted, they are replaced by their results once the article is rendered.
+	if options.Language == QueryLanguage {
+		_, err := fmt.Fprintf(w, "<pre class=\"query\">%s</pre>\n", html.EscapeString(string(code)))
+		return err
+	}
+
+	lexer := lexers.Get(options.Language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, string(code))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if len(options.Language) != 0 {
+		fmt.Fprintf(&buf, `<div class="highlight" data-language="%s">`, html.EscapeString(options.Language))
+	} else {
+		buf.WriteString(`<div class="highlight">`)
+	}
+
+	if err = highlightFormatter(options).Format(&buf, styles.Fallback, iterator); err != nil {
+		return err
+	}
+
+	buf.WriteString("</div>\n")
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// WriteHighlightCSS writes the stylesheet for highlighted code blocks using the chroma style with the given name.
+// Unknown styles fall back to chroma's default style.
+func WriteHighlightCSS(w io.Writer, style string) error {
+	return highlightFormatter(codeOptions{}).WriteCSS(w, styles.Get(style))
+}
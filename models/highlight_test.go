@@ -0,0 +1,49 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCodeInfo(t *testing.T) {
+	options := parseCodeInfo("go linenos {3,5-7,x,9-8}")
+
+	if options.Language != "go" {
+		t.Errorf("Language is %v, should be go", options.Language)
+	}
+
+	if !options.LineNumbers {
+		t.Error("Line numbers should be enabled")
+	}
+
+	if should := [][2]int{{3, 3}, {5, 7}}; !reflect.DeepEqual(options.Highlight, should) {
+		t.Errorf("Highlighted lines are %v, should be %v", options.Highlight, should)
+	}
+
+	options = parseCodeInfo("python {3, 5-7} linenos")
+	if should := [][2]int{{3, 3}, {5, 7}}; options.Language != "python" || !options.LineNumbers || !reflect.DeepEqual(options.Highlight, should) {
+		t.Errorf("Ranges with spaces should be parsed, got %+v", options)
+	}
+}
+
+func TestHighlightCode(t *testing.T) {
+	content := "```go {2}\npackage main\n\nfunc main() {}\n```\n"
+
+	html, _, err := RenderArticle([]byte(content), FormatByExtension(".md"), "test", &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(html), `<div class="highlight" data-language="go">`) {
+		t.Errorf("Code block should be highlighted: %v", string(html))
+	}
+
+	if !strings.Contains(string(html), `<span class="kd">func</span>`) {
+		t.Errorf("Keywords should be marked with classes: %v", string(html))
+	}
+
+	if !strings.Contains(string(html), `class="line hl"`) {
+		t.Errorf("Line 2 should be highlighted: %v", string(html))
+	}
+}
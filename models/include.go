@@ -0,0 +1,250 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shurcooL/sanitized_anchor_name"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MaxIncludeDepth is how deeply articles can be included in each other.
+const MaxIncludeDepth = 5
+
+var (
+	errIncludeDenied  = errors.New("not a valid article path")
+	errIncludeCycle   = errors.New("the article would include itself")
+	errIncludeDepth   = fmt.Errorf("articles can only be nested %d levels deep", MaxIncludeDepth)
+	errIncludeMissing = errors.New("article not found")
+	errIncludeSection = errors.New("section not found")
+)
+
+// includeRegexp matches include directives such as `{{include "category/article#heading"}}`.
+// Curly quotes are accepted too, as the typographer may have replaced the quotes.
+var includeRegexp = regexp.MustCompile(`^\{\{\s*include\s+["“”]([^"“”#]+)(?:#([^"“”]*))?["“”]\s*\}\}$`)
+
+var articleNameRegexp = regexp.MustCompile(`^[\w\d_ /-]+$`)
+
+// ValidArticleName checks that the name only consists of characters which are allowed in article paths.
+// As dots aren't allowed the name can't escape the content directory.
+func ValidArticleName(name string) bool {
+	return articleNameRegexp.MatchString(name)
+}
+
+// includeDirective returns the article and heading referenced by the include directive if the node is
+// a paragraph which only consists of one.
+func includeDirective(n *html.Node) (name, heading string, ok bool) {
+	if n.Type != html.ElementNode || n.DataAtom != atom.P {
+		return "", "", false
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.TextNode {
+			return "", "", false
+		}
+	}
+
+	m := includeRegexp.FindStringSubmatch(textContent(n))
+	if m == nil {
+		return "", "", false
+	}
+
+	return strings.Trim(m[1], "/ "), strings.TrimSpace(m[2]), true
+}
+
+// articleRenderer renders an article together with all articles it includes.
+type articleRenderer struct {
+	config *Config
+	// includes are the names of all included articles, in the order in which they were first included.
+	includes []string
+	// dependencies are the files of all included articles by their path without extension.
+	dependencies map[string]articleFile
+	// queried is true if the content contains a query, so it may change whenever any article changes.
+	queried bool
+}
+
+// articleFile identifies the version of an article's file.
+type articleFile struct {
+	Path    string
+	ModTime time.Time
+	Size    int64
+}
+
+// statArticle returns the file of the article at basePath, which is the article's path without extension.
+// If there is no such article the result is empty.
+func statArticle(basePath string) articleFile {
+	path, err := FindArticle(basePath)
+	if err != nil {
+		return articleFile{}
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return articleFile{}
+	}
+
+	return articleFile{Path: path, ModTime: stat.ModTime(), Size: stat.Size()}
+}
+
+// addInclude records that the article with the name was included.
+func (r *articleRenderer) addInclude(name string) {
+	for _, include := range r.includes {
+		if include == name {
+			return
+		}
+	}
+
+	r.includes = append(r.includes, name)
+}
+
+// expandIncludes replaces all include directives below root by the content of the included articles.
+// stack holds the names of the articles which are currently being rendered, the innermost one last.
+func (r *articleRenderer) expandIncludes(root *html.Node, stack []string) {
+	directives := []*html.Node{}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if _, _, ok := includeDirective(c); ok {
+				directives = append(directives, c)
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+
+	for _, directive := range directives {
+		name, heading, _ := includeDirective(directive)
+
+		replacement, err := r.include(name, heading, stack)
+		if err != nil {
+			ref := name
+			if len(heading) != 0 {
+				ref += "#" + heading
+			}
+
+			replacement = newElement(atom.P, html.Attribute{Key: "class", Val: "include-error"})
+			replacement.AppendChild(&html.Node{Type: html.TextNode, Data: fmt.Sprintf("Can't include %q: %s.", ref, err)})
+		}
+
+		directive.Parent.InsertBefore(replacement, directive)
+		directive.Parent.RemoveChild(directive)
+	}
+}
+
+// include renders the article with the name, or only the section below the heading if it isn't empty.
+// The content is wrapped in a div with the class "include".
+func (r *articleRenderer) include(name, heading string, stack []string) (*html.Node, error) {
+	// Every signed in user can read all articles, so the only restriction is that includes can't
+	// read files outside the content directory.
+	if !ValidArticleName(name) {
+		return nil, errIncludeDenied
+	}
+
+	for _, s := range stack {
+		if s == name {
+			return nil, errIncludeCycle
+		}
+	}
+
+	if len(stack) > MaxIncludeDepth {
+		return nil, errIncludeDepth
+	}
+
+	base := filepath.Join(r.config.ContentPath, filepath.FromSlash(name))
+
+	// Missing articles are recorded too, as the including article changes once they are created.
+	file := statArticle(base)
+	r.dependencies[base] = file
+	if len(file.Path) == 0 {
+		return nil, errIncludeMissing
+	}
+	path := file.Path
+
+	article, err := LoadArticle(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.addInclude(name)
+
+	// The stack is copied so that sibling includes don't share the appended name.
+	nested := append(append([]string{}, stack...), name)
+
+	root, err := r.render(article.Content, article.Format(), nested)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(heading) != 0 {
+		if root = section(root, heading); root == nil {
+			return nil, errIncludeSection
+		}
+	}
+
+	wrapper := newElement(atom.Div, html.Attribute{Key: "class", Val: "include"})
+	for root.FirstChild != nil {
+		c := root.FirstChild
+		root.RemoveChild(c)
+		wrapper.AppendChild(c)
+	}
+
+	return wrapper, nil
+}
+
+// section returns a new root holding the heading with the ID and everything up to the next heading of the
+// same or a higher level, or nil if there is no such heading below root.
+// Headings which don't have an ID yet match the ID they will get in the table of contents.
+func section(root *html.Node, id string) *html.Node {
+	want := sanitized_anchor_name.Create(id)
+
+	var find func(*html.Node) *html.Node
+	find = func(n *html.Node) *html.Node {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if _, ok := headingLevels[c.DataAtom]; ok && c.Type == html.ElementNode {
+				cid := getAttr(c, "id")
+				if len(cid) == 0 {
+					cid = sanitized_anchor_name.Create(textContent(c))
+				}
+				if cid == id || cid == want {
+					return c
+				}
+				continue
+			}
+
+			if found := find(c); found != nil {
+				return found
+			}
+		}
+
+		return nil
+	}
+
+	heading := find(root)
+	if heading == nil {
+		return nil
+	}
+
+	level := headingLevels[heading.DataAtom]
+	result := newElement(atom.Div)
+
+	for c := heading; c != nil; {
+		if l, ok := headingLevels[c.DataAtom]; ok && c.Type == html.ElementNode && c != heading && l <= level {
+			break
+		}
+
+		next := c.NextSibling
+		c.Parent.RemoveChild(c)
+		result.AppendChild(c)
+		c = next
+	}
+
+	return result
+}
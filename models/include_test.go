@@ -0,0 +1,94 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{ContentPath: dir, BaseURL: "/"}
+
+	articles := map[string]string{
+		"shared/contacts": "# Contacts\n\nCall ops.\n\n## Escalation\n\nCall the manager.\n\n## Other\n\nNot included.\n",
+		"shared/warning":  "* Warning\nOrg content\n",
+		"loop/a":          "A\n\n{{include \"loop/b\"}}\n",
+		"loop/b":          "B\n\n{{include \"loop/a\"}}\n",
+	}
+	for name, content := range articles {
+		ext := ".md"
+		if name == "shared/warning" {
+			ext = ".org"
+		}
+		if err = NewArticle(filepath.Base(name), content, filepath.Join(dir, filepath.Dir(name)), ext).Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	article := NewArticle("runbook", "# Contacts\n\n{{include \"shared/contacts\"}}\n\n{{include \"shared/contacts#escalation\"}}\n\n"+
+		"{{include \"shared/warning\"}}\n\n{{include \"../users\"}}\n\n{{include \"missing\"}}\n\n{{include \"loop/a\"}}\n", dir, ".md")
+
+	html, err := article.ContentHTML(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		"Call ops.",
+		"Org content",
+		`<h1 id="contacts-1">`,
+		`Can&#39;t include &#34;../users&#34;: not a valid article path.`,
+		`Can&#39;t include &#34;missing&#34;: article not found.`,
+		`Can&#39;t include &#34;loop/a&#34;: the article would include itself.`,
+	} {
+		if !strings.Contains(string(html), s) {
+			t.Errorf("HTML should contain %v: %v", s, string(html))
+		}
+	}
+
+	if strings.Count(string(html), "Call the manager.") != 2 || strings.Count(string(html), "Not included.") != 1 {
+		t.Errorf("Section should only include the escalation heading: %v", string(html))
+	}
+
+	expected := []string{"shared/contacts", "shared/warning", "loop/a", "loop/b"}
+	if strings.Join(article.Includes, ",") != strings.Join(expected, ",") {
+		t.Errorf("Includes are %v, should be %v", article.Includes, expected)
+	}
+}
+
+func TestIncludeDepth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i := 0; i <= MaxIncludeDepth; i++ {
+		content := "{{include \"" + string(rune('a'+i+1)) + "\"}}\n"
+		if err = NewArticle(string(rune('a'+i)), content, dir, ".md").Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	article, err := LoadArticle(filepath.Join(dir, "a.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html, err := article.ContentHTML(&Config{ContentPath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(html), "levels deep") || len(article.Includes) != MaxIncludeDepth {
+		t.Errorf("Includes should stop after %d levels: %v %v", MaxIncludeDepth, article.Includes, string(html))
+	}
+}
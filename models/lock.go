@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrDataLocked is returned by LockData if another process, e.g. a running server, uses the data directory.
+var ErrDataLocked = errors.New("the data directory is used by another process")
+
+// A DataLock keeps other processes from writing to the data directory, so that they don't overwrite each
+// other's changes to the databases they hold in memory.
+type DataLock struct {
+	file *os.File
+}
+
+// LockData takes the advisory lock at path, which is held until Unlock is called or the process exits.
+// It fails with ErrDataLocked if another process holds the lock.
+func LockData(path string) (*DataLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), privateDirPerm); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, filePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = lockFile(file); err != nil {
+		file.Close()
+
+		if errors.Is(err, ErrDataLocked) {
+			// The holder writes its PID into the file, which helps finding it.
+			if pid, err := ioutil.ReadFile(path); err == nil && len(strings.TrimSpace(string(pid))) != 0 {
+				return nil, fmt.Errorf("%w (PID %s)", ErrDataLocked, strings.TrimSpace(string(pid)))
+			}
+		}
+
+		return nil, err
+	}
+
+	if err = file.Truncate(0); err == nil {
+		_, err = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		unlockFile(file)
+		file.Close()
+		return nil, err
+	}
+
+	return &DataLock{file: file}, nil
+}
+
+// Unlock releases the lock. The lock file is kept, as removing it could let two processes lock different files.
+func (l *DataLock) Unlock() error {
+	l.file.Truncate(0)
+
+	if err := unlockFile(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package models
+
+import "os"
+
+// lockFile does nothing on systems without flock, the data directory isn't protected there.
+func lockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
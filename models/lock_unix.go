@@ -0,0 +1,22 @@
+//go:build unix
+
+package models
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock on the file without waiting for it.
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return ErrDataLocked
+	}
+
+	return err
+}
+
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
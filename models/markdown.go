@@ -0,0 +1,197 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+func init() {
+	RegisterFormat(&Format{Name: "Markdown", Extension: ".md", Renderer: RendererFunc(renderMarkdown), Link: markdownLink})
+}
+
+func markdownLink(text, dest string, image bool) string {
+	// Destinations with spaces, e.g. attachments like "my file.pdf", are only links if they are enclosed in <>.
+	if strings.ContainsAny(dest, " \t") {
+		dest = "<" + dest + ">"
+	}
+
+	if image {
+		return fmt.Sprintf("![%s](%s)", text, dest)
+	}
+
+	return fmt.Sprintf("[%s](%s)", text, dest)
+}
+
+// A MarkdownExtension adds syntax, AST transforms or render hooks to the markdown renderer.
+// Extensions are created for every wiki config, so that they can read their settings from it.
+type MarkdownExtension func(config *Config) goldmark.Extender
+
+// MarkdownSyntax is a goldmark.Extender made up of parsers, transformers and renderers.
+// It is a shortcut for extensions which don't need to configure goldmark in any other way.
+// Lower priority values take precedence: parsers with lower values are tried first and
+// renderers with lower values replace those with higher values.
+type MarkdownSyntax struct {
+	BlockParsers  []util.PrioritizedValue
+	InlineParsers []util.PrioritizedValue
+	Transformers  []util.PrioritizedValue
+	NodeRenderers []util.PrioritizedValue
+}
+
+// Extend adds the syntax to m.
+func (s *MarkdownSyntax) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(s.BlockParsers...),
+		parser.WithInlineParsers(s.InlineParsers...),
+		parser.WithASTTransformers(s.Transformers...),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(s.NodeRenderers...))
+}
+
+var markdownExtensions = struct {
+	sync.RWMutex
+	byName map[string]MarkdownExtension
+}{byName: map[string]MarkdownExtension{}}
+
+// RegisterMarkdownExtension makes the extension available under the name.
+// Which extensions are enabled is decided by Config.MarkdownExtensions.
+func RegisterMarkdownExtension(name string, extension MarkdownExtension) {
+	markdownExtensions.Lock()
+	defer markdownExtensions.Unlock()
+	markdownExtensions.byName[name] = extension
+}
+
+// codeBlockRenderer highlights fenced code blocks.
+type codeBlockRenderer struct{}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+
+	var info []byte
+	if n.Info != nil {
+		info = n.Info.Segment.Value(source)
+	}
+
+	var code bytes.Buffer
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+
+	if err := highlightCode(w, code.Bytes(), string(info)); err != nil {
+		fmt.Fprintf(w, "<pre><code>%s</code></pre>\n", util.EscapeHTML(code.Bytes()))
+	}
+
+	return ast.WalkSkipChildren, nil
+}
+
+// markdowns caches the configured markdown renderer of every wiki config.
+var markdowns sync.Map
+
+// newMarkdown sets up the markdown renderer with the extensions enabled in the config.
+func newMarkdown(config *Config) (goldmark.Markdown, error) {
+	extenders := []goldmark.Extender{
+		&MarkdownSyntax{NodeRenderers: []util.PrioritizedValue{util.Prioritized(&codeBlockRenderer{}, 100)}},
+	}
+
+	markdownExtensions.RLock()
+	for _, name := range config.MarkdownExtensions {
+		extension, ok := markdownExtensions.byName[name]
+		if !ok {
+			markdownExtensions.RUnlock()
+			return nil, fmt.Errorf("unknown markdown extension %q", name)
+		}
+		extenders = append(extenders, extension(config))
+	}
+	markdownExtensions.RUnlock()
+
+	return goldmark.New(
+		goldmark.WithExtensions(extenders...),
+		// Allows setting heading IDs, e.g. "# Heading {#id}".
+		goldmark.WithParserOptions(parser.WithAttribute()),
+		// Raw HTML is sanitized after rendering, like the output of all other formats.
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	), nil
+}
+
+// renderMarkdown converts markdown to HTML.
+func renderMarkdown(content []byte, name string, config *Config) ([]byte, error) {
+	md, ok := markdowns.Load(config)
+	if !ok {
+		m, err := newMarkdown(config)
+		if err != nil {
+			return nil, err
+		}
+		md, _ = markdowns.LoadOrStore(config, m)
+	}
+
+	var buf bytes.Buffer
+	if err := md.(goldmark.Markdown).Convert(content, &buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ContentBlocks splits content into its top level blocks, which are separated by blank lines.
+// Fenced code blocks, Org-mode blocks and front matter are kept intact even if they contain blank lines.
+func ContentBlocks(content string) []string {
+	blocks := []string{}
+	current := []string{}
+
+	if HasFrontMatter([]byte(content)) {
+		end := strings.Index(content[len(FrontMatterDelimiter):], "\n"+FrontMatterDelimiter)
+		if end != -1 {
+			end += 2*len(FrontMatterDelimiter) + 1
+			blocks = append(blocks, content[:end])
+			content = content[end:]
+		}
+	}
+	fence := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		lower := strings.ToLower(trimmed)
+
+		if len(fence) == 0 && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			fence = trimmed[:3]
+		} else if len(fence) == 0 && strings.HasPrefix(lower, "#+begin_") {
+			fence = "#+end_"
+		} else if len(fence) != 0 && strings.HasPrefix(lower, fence) {
+			fence = ""
+		}
+
+		if len(trimmed) == 0 && len(fence) == 0 {
+			if len(current) != 0 {
+				blocks = append(blocks, strings.Join(current, "\n"))
+				current = current[:0]
+			}
+			continue
+		}
+
+		current = append(current, line)
+	}
+
+	if len(current) != 0 {
+		blocks = append(blocks, strings.Join(current, "\n"))
+	}
+
+	return blocks
+}
@@ -0,0 +1,321 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+func init() {
+	RegisterMarkdownExtension("table", staticExtension(extension.NewTable(
+		// Alignment is set with the align attribute as style attributes are removed by the sanitizer.
+		extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute),
+	)))
+	RegisterMarkdownExtension("strikethrough", staticExtension(extension.Strikethrough))
+	RegisterMarkdownExtension("linkify", staticExtension(extension.Linkify))
+	RegisterMarkdownExtension("tasklist", staticExtension(extension.TaskList))
+	RegisterMarkdownExtension("definitionlist", staticExtension(extension.DefinitionList))
+	RegisterMarkdownExtension("footnote", staticExtension(extension.Footnote))
+	RegisterMarkdownExtension("typographer", staticExtension(extension.Typographer))
+	RegisterMarkdownExtension("callouts", staticExtension(&MarkdownSyntax{
+		Transformers:  []util.PrioritizedValue{util.Prioritized(&calloutTransformer{}, 100)},
+		NodeRenderers: []util.PrioritizedValue{util.Prioritized(&calloutRenderer{}, 100)},
+	}))
+	RegisterMarkdownExtension("containers", staticExtension(&MarkdownSyntax{
+		BlockParsers:  []util.PrioritizedValue{util.Prioritized(&containerParser{}, 100)},
+		NodeRenderers: []util.PrioritizedValue{util.Prioritized(&containerRenderer{}, 100)},
+	}))
+	RegisterMarkdownExtension("tickets", newTicketExtension)
+}
+
+// staticExtension is used for extensions which don't depend on the config.
+func staticExtension(extender goldmark.Extender) MarkdownExtension {
+	return func(*Config) goldmark.Extender {
+		return extender
+	}
+}
+
+// KindCallout is the goldmark node kind of callouts.
+var KindCallout = ast.NewNodeKind("Callout")
+
+// A Callout is a highlighted box, written as a blockquote which starts with its variant, e.g.
+// "> [!WARNING]".
+type Callout struct {
+	ast.BaseBlock
+	Variant string
+}
+
+// Kind implements ast.Node.
+func (n *Callout) Kind() ast.NodeKind {
+	return KindCallout
+}
+
+// Dump implements ast.Node.
+func (n *Callout) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Variant": n.Variant}, nil)
+}
+
+var calloutRegexp = regexp.MustCompile(`(?i)^\[!(note|tip|important|warning|caution)\]$`)
+
+// calloutTransformer turns blockquotes starting with a callout variant into callouts.
+type calloutTransformer struct{}
+
+// Transform implements parser.ASTTransformer.
+func (t *calloutTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	quotes := []*ast.Blockquote{}
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if q, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, q)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, q := range quotes {
+		p, ok := q.FirstChild().(*ast.Paragraph)
+		if !ok || p.Lines().Len() == 0 {
+			continue
+		}
+
+		first := p.Lines().At(0)
+		m := calloutRegexp.FindSubmatch(bytes.TrimSpace(first.Value(source)))
+		if m == nil {
+			continue
+		}
+
+		// The variant is on a line of its own, the rest of the paragraph is the callout's text.
+		for c := p.FirstChild(); c != nil; {
+			t, ok := c.(*ast.Text)
+			if !ok || t.Segment.Start >= first.Stop {
+				break
+			}
+
+			next := c.NextSibling()
+			p.RemoveChild(p, c)
+			c = next
+		}
+
+		if !p.HasChildren() {
+			q.RemoveChild(q, p)
+		}
+
+		callout := &Callout{Variant: strings.ToLower(string(m[1]))}
+		for c := q.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			callout.AppendChild(callout, c)
+			c = next
+		}
+
+		q.Parent().ReplaceChild(q.Parent(), q, callout)
+	}
+}
+
+type calloutRenderer struct{}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r *calloutRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindCallout, r.renderCallout)
+}
+
+func (r *calloutRenderer) renderCallout(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*Callout)
+
+	if entering {
+		title := strings.ToUpper(n.Variant[:1]) + n.Variant[1:]
+		fmt.Fprintf(w, "<div class=\"callout callout-%s\">\n<p class=\"callout-title\">%s</p>\n", n.Variant, title)
+	} else {
+		w.WriteString("</div>\n")
+	}
+
+	return ast.WalkContinue, nil
+}
+
+// KindContainer is the goldmark node kind of custom containers.
+var KindContainer = ast.NewNodeKind("Container")
+
+// A Container wraps markdown in a div with the container's name as class, e.g.
+//
+//	::: details Optional title
+//	Content
+//	:::
+//
+// Containers can be nested by using more colons for the outer container.
+type Container struct {
+	ast.BaseBlock
+	Name  string
+	Title string
+	fence int
+}
+
+// Kind implements ast.Node.
+func (n *Container) Kind() ast.NodeKind {
+	return KindContainer
+}
+
+// Dump implements ast.Node.
+func (n *Container) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.Name, "Title": n.Title}, nil)
+}
+
+var (
+	containerOpenRegexp  = regexp.MustCompile(`^(:{3,})\s*([a-zA-Z][\w-]*)(?:\s+(.*?))?\s*$`)
+	containerCloseRegexp = regexp.MustCompile(`^(:{3,})\s*$`)
+)
+
+type containerParser struct{}
+
+// skipLine advances the reader to the end of the current line, leaving the newline for goldmark.
+func skipLine(reader text.Reader, line []byte, segment text.Segment) {
+	newline := 0
+	if len(line) != 0 && line[len(line)-1] == '\n' {
+		newline = 1
+	}
+
+	reader.Advance(segment.Len() - newline)
+}
+
+// Trigger implements parser.BlockParser.
+func (p *containerParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+// Open implements parser.BlockParser.
+func (p *containerParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 {
+		return nil, parser.NoChildren
+	}
+
+	m := containerOpenRegexp.FindSubmatch(line[pos:])
+	if m == nil {
+		return nil, parser.NoChildren
+	}
+
+	skipLine(reader, line, segment)
+
+	return &Container{Name: strings.ToLower(string(m[2])), Title: string(m[3]), fence: len(m[1])}, parser.HasChildren
+}
+
+// Continue implements parser.BlockParser.
+func (p *containerParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+
+	if m := containerCloseRegexp.FindSubmatch(bytes.TrimLeft(line, " \t")); m != nil && len(m[1]) >= node.(*Container).fence {
+		skipLine(reader, line, segment)
+		return parser.Close
+	}
+
+	return parser.Continue | parser.HasChildren
+}
+
+// Close implements parser.BlockParser.
+func (p *containerParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+// CanInterruptParagraph implements parser.BlockParser.
+func (p *containerParser) CanInterruptParagraph() bool {
+	return true
+}
+
+// CanAcceptIndentedLine implements parser.BlockParser.
+func (p *containerParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type containerRenderer struct{}
+
+// RegisterFuncs implements renderer.NodeRenderer.
+func (r *containerRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindContainer, r.renderContainer)
+}
+
+func (r *containerRenderer) renderContainer(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*Container)
+
+	if !entering {
+		w.WriteString("</div>\n")
+		return ast.WalkContinue, nil
+	}
+
+	fmt.Fprintf(w, "<div class=\"%s\">\n", n.Name)
+	if len(n.Title) != 0 {
+		fmt.Fprintf(w, "<p class=\"container-title\">%s</p>\n", util.EscapeHTML([]byte(n.Title)))
+	}
+
+	return ast.WalkContinue, nil
+}
+
+// ticketParser links ticket IDs such as "JIRA-123" to the issue tracker.
+type ticketParser struct {
+	regexp *regexp.Regexp
+	url    string
+}
+
+// newTicketExtension links the IDs of tickets with one of the config's TicketPrefixes to the config's TicketURL.
+// Nothing is linked if either isn't set.
+func newTicketExtension(config *Config) goldmark.Extender {
+	if len(config.TicketURL) == 0 || len(config.TicketPrefixes) == 0 {
+		return &MarkdownSyntax{}
+	}
+
+	prefixes := make([]string, len(config.TicketPrefixes))
+	for i, prefix := range config.TicketPrefixes {
+		prefixes[i] = regexp.QuoteMeta(strings.ToUpper(prefix))
+	}
+
+	p := &ticketParser{
+		regexp: regexp.MustCompile(`^(?:` + strings.Join(prefixes, "|") + `)-\d+\b`),
+		url:    config.TicketURL,
+	}
+
+	return &MarkdownSyntax{InlineParsers: []util.PrioritizedValue{util.Prioritized(p, 1000)}}
+}
+
+// Trigger implements parser.InlineParser.
+// Inline parsers can only be triggered by punctuation or spaces, which includes the start of a line.
+func (p *ticketParser) Trigger() []byte {
+	return []byte{' ', '(', '*', '_', '~'}
+}
+
+// Parse implements parser.InlineParser.
+func (p *ticketParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	if pc.IsInLinkLabel() {
+		return nil
+	}
+
+	line, segment := block.PeekLine()
+
+	// The trigger character isn't part of the ID.
+	consumes := 0
+	if c := line[0]; c == ' ' || c == '(' || c == '*' || c == '_' || c == '~' {
+		consumes = 1
+	}
+
+	m := p.regexp.FindIndex(line[consumes:])
+	if m == nil {
+		return nil
+	}
+
+	if consumes != 0 {
+		ast.MergeOrAppendTextSegment(parent, segment.WithStop(segment.Start+consumes))
+	}
+
+	id := text.NewSegment(segment.Start+consumes, segment.Start+consumes+m[1])
+	block.Advance(consumes + m[1])
+
+	link := ast.NewLink()
+	link.Destination = []byte(strings.Replace(p.url, "{id}", string(id.Value(block.Source())), -1))
+	link.AppendChild(link, ast.NewTextSegment(id))
+
+	return link
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func renderTestMarkdown(t *testing.T, content string, config *Config) string {
+	html, _, err := RenderArticle([]byte(content), FormatByExtension(".md"), "test", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(html)
+}
+
+func TestMarkdownCallouts(t *testing.T) {
+	config := &Config{MarkdownExtensions: []string{"callouts"}}
+	html := renderTestMarkdown(t, "> [!WARNING]\n> Don't do this.\n\n> Just a quote\n", config)
+
+	if !strings.Contains(html, `<div class="callout callout-warning">`) || !strings.Contains(html, `<p class="callout-title">Warning</p>`) {
+		t.Errorf("Callout should be rendered: %v", html)
+	}
+
+	if strings.Contains(html, "[!WARNING]") || !strings.Contains(html, "<p>Don&#39;t do this.</p>") {
+		t.Errorf("Marker should be removed from the callout's text: %v", html)
+	}
+
+	if !strings.Contains(html, "<blockquote>\n<p>Just a quote</p>") {
+		t.Errorf("Other quotes should be kept: %v", html)
+	}
+}
+
+func TestMarkdownContainers(t *testing.T) {
+	config := &Config{MarkdownExtensions: []string{"containers"}}
+	html := renderTestMarkdown(t, "::::: box Outer\n::: notification\n*Inner*\n:::\n:::::\n\nAfter\n", config)
+
+	should := "<div class=\"box\">\n<p class=\"container-title\">Outer</p>\n<div class=\"notification\">\n<p><em>Inner</em></p>\n</div>\n</div>\n<p>After</p>"
+	if !strings.Contains(html, should) {
+		t.Errorf("HTML is %q, should contain %q", html, should)
+	}
+}
+
+func TestMarkdownTickets(t *testing.T) {
+	config := &Config{
+		MarkdownExtensions: []string{"tickets"},
+		TicketURL:          "https://tracker.example.com/browse/{id}",
+		TicketPrefixes:     []string{"ops"},
+	}
+	html := renderTestMarkdown(t, "OPS-12 fixes (OPS-3), not XOPS-4, OPS-5a or `OPS-6`.\n", config)
+
+	for _, id := range []string{"OPS-12", "OPS-3"} {
+		if !strings.Contains(html, `<a href="https://tracker.example.com/browse/`+id+`">`+id+`</a>`) {
+			t.Errorf("%v should be linked: %v", id, html)
+		}
+	}
+
+	if strings.Count(html, "<a ") != 2 {
+		t.Errorf("Only two tickets should be linked: %v", html)
+	}
+}
+
+func TestMarkdownUnknownExtension(t *testing.T) {
+	_, _, err := RenderArticle([]byte("text"), FormatByExtension(".md"), "test", &Config{MarkdownExtensions: []string{"missing"}})
+	if err == nil {
+		t.Error("Unknown extensions should fail rendering")
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+
+	"alexandria.app/metrics"
+)
+
+var (
+	argon2Durations = metrics.NewHistogram("alexandria_argon2_hash_duration_seconds",
+		"Duration of hashing a password with Argon2.", []float64{.05, .1, .25, .5, 1, 2.5, 5, 10})
+	renderCacheRequests = metrics.NewCounter("alexandria_render_cache_requests_total",
+		"Number of articles loaded through the render cache by result, either hit or miss.", "result")
+	storageErrors = metrics.NewCounter("alexandria_storage_errors_total",
+		"Number of failed writes by storage, e.g. users or articles.", "storage")
+)
+
+func init() {
+	// All series are created up front, so that they are exported with 0 before anything happened.
+	for _, result := range []string{"hit", "miss"} {
+		renderCacheRequests.Add(0, result)
+	}
+	for _, storage := range []string{"articles", "revisions", "sessions", "users", "webhooks"} {
+		storageErrors.Add(0, storage)
+	}
+}
+
+// hashPassword hashes the password with Argon2id and records how long it took.
+func hashPassword(password, salt string, iterations, memory uint32, threads uint8, keyLen uint32) []byte {
+	start := time.Now()
+	defer func() { argon2Durations.Observe(time.Since(start).Seconds()) }()
+
+	return argon2.IDKey([]byte(password), []byte(salt), iterations, memory, threads, keyLen)
+}
+
+// countStorageError counts *err as a failure of the storage if it isn't nil. It is meant to be deferred with a
+// pointer to the named error result of a function writing to the storage.
+func countStorageError(storage string, err *error) {
+	if *err != nil {
+		storageErrors.Inc(storage)
+	}
+}
+
+// ContentStats returns the number of articles in the content directory and their total size in bytes.
+// Attachments aren't counted.
+func ContentStats(config *Config) (int, int64, error) {
+	count, size := 0, int64(0)
+
+	err := filepath.Walk(config.ContentPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == config.ContentPath {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			if strings.HasSuffix(info.Name(), AttachmentDirectorySuffix) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if IsArticleFile(info.Name()) {
+			count++
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return count, size, err
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+
+	"github.com/niklasfasching/go-org/org"
+)
+
+func init() {
+	RegisterFormat(&Format{Name: "Org-mode", Extension: ".org", Renderer: RendererFunc(renderOrg), Link: orgLink})
+}
+
+func orgLink(text, dest string, image bool) string {
+	if image {
+		return fmt.Sprintf("[[%s]]", dest)
+	}
+
+	return fmt.Sprintf("[[%s][%s]]", dest, text)
+}
+
+// go-org numbers headlines, those IDs are removed so that IDs are generated from the headline's text instead.
+var orgHeadlineIDRegexp = regexp.MustCompile(`(<h[1-6]) id="headline-\d+"`)
+
+// renderOrg converts Org-mode documents to HTML.
+func renderOrg(content []byte, name string, config *Config) ([]byte, error) {
+	conf := org.New().Silent()
+	// #+INCLUDE would allow reading any file on the server.
+	conf.ReadFile = func(string) ([]byte, error) {
+		return nil, errors.New("includes are not supported")
+	}
+	// Articles get their title and table of contents from Alexandria like in all other formats.
+	conf.DefaultSettings["OPTIONS"] = "toc:nil <:t e:t f:t pri:t todo:t tags:t title:nil ealb:nil"
+
+	writer := org.NewHTMLWriter()
+	writer.HighlightCodeBlock = func(source, lang string, inline bool) string {
+		var buf bytes.Buffer
+		if err := highlightCode(&buf, []byte(source), lang); err != nil {
+			return fmt.Sprintf("<pre>%s</pre>", html.EscapeString(source))
+		}
+		return buf.String()
+	}
+
+	// go-org only displays links as images if they are files, so attachments are passed as file links.
+	content = bytes.ReplaceAll(content, []byte("[["+AttachmentLinkPrefix), []byte("[[file:"+AttachmentLinkPrefix))
+
+	output, err := conf.Parse(bytes.NewReader(content), name).Write(writer)
+	if err != nil {
+		return nil, err
+	}
+
+	return orgHeadlineIDRegexp.ReplaceAll([]byte(output), []byte("$1")), nil
+}
@@ -0,0 +1,461 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// QueryLanguage is the language of code blocks which contain a query, e.g. "```query".
+// Instead of the code block, the articles matching the query are displayed as table.
+const QueryLanguage = "query"
+
+// A Query selects articles by their fields, e.g.
+//
+//	from services
+//	where status = "deprecated"
+//	sort owner
+//	columns title, owner, review_by
+//	limit 10
+//
+// Besides the custom fields of articles, the fields "title", "path" and "last_edited" can be used.
+type Query struct {
+	// From is the category the articles are in, including subcategories. All articles are queried if it is empty.
+	From       string
+	Conditions []*Condition
+	Sort       string
+	Descending bool
+	// Columns are the fields displayed for every article.
+	Columns []string
+	Limit   int
+}
+
+// A Condition is part of a query's where clause. All conditions have to be true for an article to match.
+type Condition struct {
+	Field    string
+	Operator string
+	Value    interface{}
+}
+
+// A QueryResult is an article matching a query.
+type QueryResult struct {
+	Name string
+	Meta Metadata
+}
+
+var (
+	queryFieldRegexp     = regexp.MustCompile(`^[\w-]+$`)
+	queryConditionRegexp = regexp.MustCompile(`^([\w-]+)\s*(=|!=|<=|>=|<|>)\s*(.+)$`)
+)
+
+// parseQueryValue parses a value in a condition as TOML, e.g. "deprecated" or 2024-06-01.
+// Values which aren't valid TOML are treated as strings, so quotes can be left out.
+func parseQueryValue(raw string) interface{} {
+	var value struct{ V interface{} }
+	if _, err := toml.Decode("V = "+raw, &value); err != nil {
+		return raw
+	}
+
+	return value.V
+}
+
+// ParseQuery reads a query, which has one clause per line.
+func ParseQuery(src string) (*Query, error) {
+	q := &Query{Conditions: []*Condition{}, Columns: []string{}}
+
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		clause, arg := line, ""
+		if index := strings.IndexAny(line, " \t"); index != -1 {
+			clause, arg = line[:index], strings.TrimSpace(line[index:])
+		}
+
+		switch strings.ToLower(clause) {
+		case "from":
+			q.From = strings.Trim(arg, "/ ")
+			if len(q.From) != 0 && !ValidArticleName(q.From) {
+				return nil, fmt.Errorf("invalid category %q", arg)
+			}
+		case "where":
+			m := queryConditionRegexp.FindStringSubmatch(arg)
+			if m == nil {
+				return nil, fmt.Errorf("invalid condition %q", arg)
+			}
+			q.Conditions = append(q.Conditions, &Condition{Field: m[1], Operator: m[2], Value: parseQueryValue(strings.TrimSpace(m[3]))})
+		case "sort":
+			fields := strings.Fields(arg)
+			if len(fields) == 0 || len(fields) > 2 || !queryFieldRegexp.MatchString(fields[0]) {
+				return nil, fmt.Errorf("invalid sort %q", arg)
+			}
+			q.Sort = fields[0]
+			if len(fields) == 2 {
+				switch strings.ToLower(fields[1]) {
+				case "asc":
+				case "desc":
+					q.Descending = true
+				default:
+					return nil, fmt.Errorf("invalid sort order %q", fields[1])
+				}
+			}
+		case "columns":
+			for _, column := range strings.Split(arg, ",") {
+				column = strings.TrimSpace(column)
+				if !queryFieldRegexp.MatchString(column) {
+					return nil, fmt.Errorf("invalid column %q", column)
+				}
+				q.Columns = append(q.Columns, column)
+			}
+		case "limit":
+			limit, err := strconv.Atoi(arg)
+			if err != nil || limit < 1 {
+				return nil, fmt.Errorf("invalid limit %q", arg)
+			}
+			q.Limit = limit
+		default:
+			return nil, fmt.Errorf("unknown clause %q", clause)
+		}
+	}
+
+	// Without columns, the fields the query uses are displayed.
+	if len(q.Columns) == 0 {
+		q.Columns = append(q.Columns, "title")
+		for _, c := range q.Conditions {
+			q.addColumn(c.Field)
+		}
+		q.addColumn(q.Sort)
+	}
+
+	return q, nil
+}
+
+func (q *Query) addColumn(field string) {
+	if len(field) == 0 {
+		return
+	}
+
+	for _, column := range q.Columns {
+		if column == field {
+			return
+		}
+	}
+
+	q.Columns = append(q.Columns, field)
+}
+
+// Field returns the value of the field with the name, which is matched case insensitively.
+func (r *QueryResult) Field(name string) (interface{}, bool) {
+	switch strings.ToLower(name) {
+	case "title":
+		return r.Meta.Title, true
+	case "path":
+		return r.Name, true
+	case "last_edited":
+		return time.Unix(r.Meta.LastEditedAt, 0), true
+	}
+
+	if value, ok := r.Meta.Fields[name]; ok {
+		return value, true
+	}
+
+	for key, value := range r.Meta.Fields {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
+
+// compareValues returns -1, 0 or 1 if a is less than, equal to or greater than b.
+// ok is false if the values can't be compared, e.g. because one is a string and the other a number.
+func compareValues(a, b interface{}) (result int, ok bool) {
+	cmp := func(less, equal bool) int {
+		if equal {
+			return 0
+		} else if less {
+			return -1
+		}
+		return 1
+	}
+
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			return cmp(!a && b, a == b), true
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return cmp(a.Before(b), a.Equal(b)), true
+		}
+	case int64, float64:
+		x, y := toFloat(a), toFloat(b)
+		if _, isInt := b.(int64); isInt {
+			return cmp(x < y, x == y), true
+		}
+		if _, isFloat := b.(float64); isFloat {
+			return cmp(x < y, x == y), true
+		}
+	}
+
+	return 0, false
+}
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+
+	return 0
+}
+
+// Match checks if the condition is true for the result. Only "!=" matches articles which don't have the field.
+func (c *Condition) Match(r *QueryResult) bool {
+	value, ok := r.Field(c.Field)
+	if !ok {
+		return c.Operator == "!="
+	}
+
+	result, ok := compareValues(value, c.Value)
+	if !ok {
+		return c.Operator == "!="
+	}
+
+	switch c.Operator {
+	case "=":
+		return result == 0
+	case "!=":
+		return result != 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	}
+
+	return false
+}
+
+// Run returns all articles matching the query, sorted by the query's sort field and then by path.
+// Templates are only found if the query is limited to their category.
+func (q *Query) Run(config *Config) ([]*QueryResult, error) {
+	results := []*QueryResult{}
+	root := filepath.Join(config.ContentPath, filepath.FromSlash(q.From))
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			if strings.HasSuffix(info.Name(), AttachmentDirectorySuffix) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !IsArticleFile(info.Name()) {
+			return nil
+		}
+
+		// Articles which can't be read are skipped, so that one broken article doesn't break all queries.
+		article, err := LoadArticle(path)
+		if err != nil {
+			return nil
+		}
+
+		name := article.Name(config)
+		if IsArticleTemplate(config, name) && !IsArticleTemplate(config, q.From) {
+			return nil
+		}
+
+		result := &QueryResult{Name: name, Meta: article.Meta}
+		for _, c := range q.Conditions {
+			if !c.Match(result) {
+				return nil
+			}
+		}
+
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if len(q.Sort) != 0 {
+			a, aok := results[i].Field(q.Sort)
+			b, bok := results[j].Field(q.Sort)
+
+			// Articles without the field are always last.
+			if aok != bok {
+				return aok
+			}
+
+			if result, ok := compareValues(a, b); ok && result != 0 {
+				return (result < 0) != q.Descending
+			}
+		}
+
+		return results[i].Name < results[j].Name
+	})
+
+	if q.Limit != 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+
+	return results, nil
+}
+
+// FormatFieldValue converts the value of an article's field to text.
+func FormatFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		// Dates without time are decoded into the "date-local" time zone.
+		if v.Location().String() == "date-local" {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04")
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, value := range v {
+			values[i] = FormatFieldValue(value)
+		}
+		return strings.Join(values, ", ")
+	case nil:
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+// queryTable renders the results as table with the query's columns. The title links to the article.
+func queryTable(q *Query, results []*QueryResult, config *Config) *html.Node {
+	if len(results) == 0 {
+		p := newElement(atom.P, html.Attribute{Key: "class", Val: "query-empty"})
+		p.AppendChild(&html.Node{Type: html.TextNode, Data: "No articles match the query."})
+		return p
+	}
+
+	table := newElement(atom.Table, html.Attribute{Key: "class", Val: "query"})
+
+	head := newElement(atom.Tr)
+	for _, column := range q.Columns {
+		th := newElement(atom.Th)
+		th.AppendChild(&html.Node{Type: html.TextNode, Data: column})
+		head.AppendChild(th)
+	}
+	thead := newElement(atom.Thead)
+	thead.AppendChild(head)
+	table.AppendChild(thead)
+
+	tbody := newElement(atom.Tbody)
+	for _, result := range results {
+		row := newElement(atom.Tr)
+
+		for _, column := range q.Columns {
+			td := newElement(atom.Td)
+			value, _ := result.Field(column)
+			text := &html.Node{Type: html.TextNode, Data: FormatFieldValue(value)}
+
+			if strings.EqualFold(column, "title") {
+				link := newElement(atom.A, html.Attribute{Key: "href", Val: config.BaseURL + "articles/" + (&url.URL{Path: result.Name}).EscapedPath()})
+				link.AppendChild(text)
+				td.AppendChild(link)
+			} else {
+				td.AppendChild(text)
+			}
+
+			row.AppendChild(td)
+		}
+
+		tbody.AppendChild(row)
+	}
+	table.AppendChild(tbody)
+
+	return table
+}
+
+// isQueryBlock checks if the node is the code block of a query.
+func isQueryBlock(n *html.Node) bool {
+	return n.Type == html.ElementNode && n.DataAtom == atom.Pre && getAttr(n, "class") == "query"
+}
+
+// rawText returns all text below the node, keeping whitespace intact.
+func rawText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(rawText(c))
+	}
+
+	return b.String()
+}
+
+// expandQueries replaces all query blocks below root by tables of the articles matching the queries.
+func (r *articleRenderer) expandQueries(root *html.Node) {
+	blocks := []*html.Node{}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if isQueryBlock(c) {
+				blocks = append(blocks, c)
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+
+	for _, block := range blocks {
+		var replacement *html.Node
+
+		q, err := ParseQuery(rawText(block))
+		var results []*QueryResult
+		if err == nil {
+			results, err = q.Run(r.config)
+		}
+
+		if err != nil {
+			replacement = newElement(atom.P, html.Attribute{Key: "class", Val: "query-error"})
+			replacement.AppendChild(&html.Node{Type: html.TextNode, Data: fmt.Sprintf("Invalid query: %s.", err)})
+		} else {
+			r.queried = true
+			replacement = queryTable(q, results, r.config)
+		}
+
+		block.Parent.InsertBefore(replacement, block)
+		block.Parent.RemoveChild(block)
+	}
+}
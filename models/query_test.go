@@ -0,0 +1,79 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery("from services/\nwhere status = deprecated\nwhere version >= 2\nsort owner desc\nlimit 5\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if q.From != "services" || len(q.Conditions) != 2 || q.Sort != "owner" || !q.Descending || q.Limit != 5 {
+		t.Errorf("Query wasn't parsed correctly: %+v", q)
+	}
+
+	if q.Conditions[0].Value != "deprecated" || q.Conditions[1].Value != int64(2) {
+		t.Errorf("Values should be parsed as TOML: %#v %#v", q.Conditions[0].Value, q.Conditions[1].Value)
+	}
+
+	if strings.Join(q.Columns, ",") != "title,status,version,owner" {
+		t.Errorf("Columns are %v, should be the used fields", q.Columns)
+	}
+
+	for _, src := range []string{"select *", "from ../etc", "where status", "sort a b c", "limit -1"} {
+		if _, err = ParseQuery(src); err == nil {
+			t.Errorf("Query %q should be invalid", src)
+		}
+	}
+}
+
+func TestRenderQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{ContentPath: dir, BaseURL: "/", ArticleTemplates: "templates"}
+
+	articles := map[string]map[string]interface{}{
+		"services/api":   {"owner": "Zoe", "status": "deprecated"},
+		"services/web":   {"owner": "Adam", "status": "deprecated"},
+		"services/db":    {"owner": "Bob", "status": "active"},
+		"other/legacy":   {"owner": "Carl", "status": "deprecated"},
+		"templates/base": {"owner": "Dan", "status": "deprecated"},
+	}
+	for name, fields := range articles {
+		article := NewArticle(filepath.Base(name), "Text", filepath.Join(dir, filepath.Dir(name)), ".md")
+		article.Meta.Fields = fields
+		if err = article.Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := "```query\nwhere status = \"deprecated\"\nsort owner\ncolumns title, owner\n```\n\n```query\nfrom services\nwhere owner = Nobody\n```\n\n```query\nwhere\n```\n"
+
+	html, _, err := RenderArticle([]byte(content), FormatByExtension(".md"), "index", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<table class="query"><thead><tr><th>title</th><th>owner</th></tr></thead><tbody>` +
+		`<tr><td><a href="/articles/services/web">web</a></td><td>Adam</td></tr>` +
+		`<tr><td><a href="/articles/other/legacy">legacy</a></td><td>Carl</td></tr>` +
+		`<tr><td><a href="/articles/services/api">api</a></td><td>Zoe</td></tr>` +
+		`</tbody></table>`
+	if !strings.Contains(string(html), expected) {
+		t.Errorf("HTML should contain the sorted results without templates %v: %v", expected, string(html))
+	}
+
+	if !strings.Contains(string(html), "No articles match the query.") || !strings.Contains(string(html), `<p class="query-error">Invalid query: `) {
+		t.Errorf("HTML should contain the empty result and the invalid query: %v", string(html))
+	}
+}
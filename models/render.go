@@ -0,0 +1,144 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// imageSizes tells browsers how wide images are displayed, which is at most the width of the content container.
+const imageSizes = "(max-width: 1344px) 100vw, 1344px"
+
+// srcset lists all thumbnail variants of the image at url.
+func srcset(url string) string {
+	variants := make([]string, len(ThumbnailWidths))
+	for i, width := range ThumbnailWidths {
+		variants[i] = fmt.Sprintf("%s?w=%d %dw", url, width, width)
+	}
+
+	return strings.Join(variants, ", ")
+}
+
+// resolveLink turns references to attachments into URLs.
+// "attachment:file.png" refers to the current article's attachment, "attachment:category/article/file.png" to
+// the attachment of another article.
+func resolveLink(dest, name string, config *Config) string {
+	if !strings.HasPrefix(dest, AttachmentLinkPrefix) {
+		return dest
+	}
+
+	ref := strings.TrimPrefix(dest, AttachmentLinkPrefix)
+	// Markdown escapes destinations, e.g. spaces in file names, so the name would be escaped twice otherwise.
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+	if !strings.Contains(ref, "/") {
+		ref = path.Join(name, ref)
+	}
+
+	return AttachmentURL(config, path.Dir(ref), path.Base(ref))
+}
+
+// AttachmentURL returns the URL of the attachment with the name, which belongs to the article with the wiki path articleName.
+func AttachmentURL(config *Config, articleName, name string) string {
+	return config.BaseURL + "attachments/" + (&url.URL{Path: articleName + "/" + name}).EscapedPath()
+}
+
+// resolveLinks resolves the destinations of all links and images below n.
+// Images which are attachments get a srcset, so that browsers can pick a smaller variant.
+func resolveLinks(n *html.Node, name string, config *Config) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+
+		key := ""
+		switch c.DataAtom {
+		case atom.A:
+			key = "href"
+		case atom.Img:
+			key = "src"
+		}
+
+		if dest := getAttr(c, key); len(key) != 0 && strings.HasPrefix(dest, AttachmentLinkPrefix) {
+			resolved := resolveLink(dest, name, config)
+			setAttr(c, key, resolved)
+
+			if c.DataAtom == atom.Img && resizableImage(dest) {
+				c.Attr = append([]html.Attribute{
+					{Key: "srcset", Val: srcset(resolved)},
+					{Key: "sizes", Val: imageSizes},
+					{Key: "loading", Val: "lazy"},
+				}, c.Attr...)
+			}
+		}
+
+		resolveLinks(c, name, config)
+	}
+}
+
+// RenderArticle converts content written in the format to HTML and builds the table of contents.
+// name is the path of the article inside the wiki, which is used to resolve relative references.
+// Whatever the format, the HTML is sanitized and gets the same links, included articles, query results,
+// heading anchors and table of contents.
+func RenderArticle(content []byte, format *Format, name string, config *Config) ([]byte, *TableOfContents, error) {
+	output, toc, _, err := renderArticle(content, format, name, config)
+	return output, toc, err
+}
+
+// renderArticle is RenderArticle, which additionally returns the renderer.
+// It knows which articles were included and whether the content contains queries.
+func renderArticle(content []byte, format *Format, name string, config *Config) ([]byte, *TableOfContents, *articleRenderer, error) {
+	r := &articleRenderer{config: config, includes: []string{}, dependencies: map[string]articleFile{}}
+
+	root, err := r.render(content, format, []string{name})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The table of contents is built once all articles are included, so that heading IDs are unique on the whole page.
+	toc := buildTOC(root)
+
+	var buf bytes.Buffer
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if err = html.Render(&buf, c); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return buf.Bytes(), toc, r, nil
+}
+
+// render converts the content of the last article in stack to sanitized HTML below a new root element
+// and expands its include directives and queries.
+func (r *articleRenderer) render(content []byte, format *Format, stack []string) (*html.Node, error) {
+	name := stack[len(stack)-1]
+
+	output, err := format.Renderer.Render(content, name, r.config)
+	if err != nil {
+		return nil, err
+	}
+
+	root := newElement(atom.Div)
+
+	nodes, err := html.ParseFragment(bytes.NewReader(output), root)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+
+	sanitize(root)
+	resolveLinks(root, name, r.config)
+	r.expandIncludes(root, stack)
+	r.expandQueries(root)
+
+	return root, nil
+}
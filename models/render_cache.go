@@ -0,0 +1,133 @@
+package models
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A RenderedArticle is an article together with its content rendered to HTML.
+type RenderedArticle struct {
+	*Article
+	HTML []byte
+	// ModTime is the latest modification time of the article and the articles it includes. It is zero if the
+	// article contains queries, as their results change without the article's files changing.
+	ModTime time.Time
+}
+
+type renderCacheEntry struct {
+	rendered     *RenderedArticle
+	file         articleFile
+	dependencies map[string]articleFile
+	// queried is set if the article contains queries, then generation is the content generation it was
+	// rendered at.
+	queried    bool
+	generation uint64
+}
+
+// A RenderCache keeps rendered articles in memory, so that articles are only read and rendered again once they
+// or an article they include change.
+type RenderCache struct {
+	config  *Config
+	mutex   sync.RWMutex
+	entries map[string]*renderCacheEntry
+}
+
+// NewRenderCache creates an empty cache for articles rendered with the config.
+func NewRenderCache(config *Config) *RenderCache {
+	return &RenderCache{config: config, entries: map[string]*renderCacheEntry{}}
+}
+
+// contentGeneration is increased whenever an article or attachment is written, moved or deleted, so that cached
+// articles with queries notice that their results may have changed without walking the content directory.
+// Files changed by other processes aren't counted.
+var contentGeneration uint64
+
+func contentChanged() {
+	atomic.AddUint64(&contentGeneration, 1)
+}
+
+// valid checks that nothing the entry depends on changed since it was rendered.
+func (e *renderCacheEntry) valid(file articleFile) bool {
+	if e.file != file {
+		return false
+	}
+
+	for base, dependency := range e.dependencies {
+		if statArticle(base) != dependency {
+			return false
+		}
+	}
+
+	if e.queried && atomic.LoadUint64(&contentGeneration) != e.generation {
+		return false
+	}
+
+	return true
+}
+
+// Load returns the article at path with its rendered content. The article is only read and rendered if it isn't
+// cached yet or if it, or an article it includes, changed. Articles containing queries are rendered again whenever
+// any article or attachment changed.
+// The returned article is shared and must not be modified.
+func (c *RenderCache) Load(path string) (*RenderedArticle, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		c.mutex.Lock()
+		delete(c.entries, path)
+		c.mutex.Unlock()
+		return nil, err
+	}
+	file := articleFile{Path: path, ModTime: stat.ModTime(), Size: stat.Size()}
+
+	c.mutex.RLock()
+	entry := c.entries[path]
+	c.mutex.RUnlock()
+
+	if entry != nil && entry.valid(file) {
+		renderCacheRequests.Inc("hit")
+		return entry.rendered, nil
+	}
+	renderCacheRequests.Inc("miss")
+
+	// The generation is taken before rendering, so that changes made while rendering invalidate the entry.
+	generation := atomic.LoadUint64(&contentGeneration)
+
+	article, err := LoadArticle(path)
+	if err != nil {
+		return nil, err
+	}
+
+	output, toc, r, err := renderArticle(article.Content, article.Format(), article.Name(c.config), c.config)
+	if err != nil {
+		return nil, err
+	}
+
+	article.TOC = toc
+	article.Includes = r.includes
+
+	entry = &renderCacheEntry{
+		rendered:     &RenderedArticle{Article: article, HTML: output, ModTime: file.ModTime},
+		file:         file,
+		dependencies: r.dependencies,
+	}
+
+	for _, dependency := range r.dependencies {
+		if dependency.ModTime.After(entry.rendered.ModTime) {
+			entry.rendered.ModTime = dependency.ModTime
+		}
+	}
+
+	if r.queried {
+		entry.queried = true
+		entry.generation = generation
+		entry.rendered.ModTime = time.Time{}
+	}
+
+	c.mutex.Lock()
+	c.entries[path] = entry
+	c.mutex.Unlock()
+
+	return entry.rendered, nil
+}
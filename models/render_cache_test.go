@@ -0,0 +1,98 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{ContentPath: dir, BaseURL: "/"}
+	cache := NewRenderCache(config)
+
+	write := func(name, content string, modTime time.Time) {
+		article := NewArticle(name, content, dir, ".md")
+		if err := article.Write(); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(article.Path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write("page", "{{include \"shared\"}}\n", start)
+	write("shared", "Old", start)
+	write("index", "```query\ncolumns title\n```\n", start)
+
+	first, err := cache.Load(filepath.Join(dir, "page.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := cache.Load(filepath.Join(dir, "page.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Error("Unchanged article should be cached")
+	}
+
+	write("shared", "New content", start.Add(time.Minute))
+
+	third, err := cache.Load(filepath.Join(dir, "page.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if third == second || !strings.Contains(string(third.HTML), "New content") {
+		t.Errorf("Article should be rendered again after an included article changed: %v", string(third.HTML))
+	}
+
+	if !third.ModTime.Equal(start.Add(time.Minute)) {
+		t.Errorf("ModTime is %v, should be the included article's %v", third.ModTime, start.Add(time.Minute))
+	}
+
+	index, err := cache.Load(filepath.Join(dir, "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err := cache.Load(filepath.Join(dir, "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cached != index {
+		t.Error("Article with queries should be cached while no article changes")
+	}
+
+	write("other", "Other", start)
+
+	index, err = cache.Load(filepath.Join(dir, "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(index.HTML), "other") {
+		t.Errorf("Query should list new articles: %v", string(index.HTML))
+	}
+
+	if !index.ModTime.IsZero() {
+		t.Errorf("ModTime of articles with queries should be zero, got %v", index.ModTime)
+	}
+
+	if _, err = cache.Load(filepath.Join(dir, "missing.md")); !os.IsNotExist(err) {
+		t.Errorf("Missing article should return a not exist error, got %v", err)
+	}
+}
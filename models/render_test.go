@@ -0,0 +1,76 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderArticleSanitize(t *testing.T) {
+	content := "<script>alert(1)</script>\n\n<p onclick=\"alert(2)\">Text</p>\n\n[Link](javascript:alert(3)) <custom>kept</custom>\n"
+
+	html, _, err := RenderArticle([]byte(content), FormatByExtension(".md"), "test", &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"<script", "alert(1)", "onclick", "javascript:", "<custom>"} {
+		if strings.Contains(string(html), s) {
+			t.Errorf("HTML should not contain %v: %v", s, string(html))
+		}
+	}
+
+	if !strings.Contains(string(html), "kept") {
+		t.Errorf("Content of unknown elements should be kept: %v", string(html))
+	}
+}
+
+func TestRenderArticleLinks(t *testing.T) {
+	content := "![Diagram](attachment:diagram.png) [Other](attachment:other/article/file.pdf)\n"
+
+	html, _, err := RenderArticle([]byte(content), FormatByExtension(".md"), "category/article", &Config{BaseURL: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(html), `src="/attachments/category/article/diagram.png"`) || !strings.Contains(string(html), `srcset="/attachments/category/article/diagram.png?w=320 320w`) {
+		t.Errorf("Image should be resolved to the attachment: %v", string(html))
+	}
+
+	if !strings.Contains(string(html), `href="/attachments/other/article/file.pdf"`) {
+		t.Errorf("Link should be resolved to the other article's attachment: %v", string(html))
+	}
+}
+
+func TestRenderAttachmentLinkWithSpaces(t *testing.T) {
+	for _, ext := range []string{".md", ".org"} {
+		format := FormatByExtension(ext)
+		content := format.Link("my file.pdf", AttachmentLinkPrefix+"my file.pdf", false) + "\n\n" +
+			format.Link("my image", AttachmentLinkPrefix+"my image.png", true) + "\n"
+
+		html, _, err := RenderArticle([]byte(content), format, "article", &Config{BaseURL: "/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(html), `href="/attachments/article/my%20file.pdf"`) || !strings.Contains(string(html), `src="/attachments/article/my%20image.png"`) {
+			t.Errorf("%s: Links to attachments with spaces in their names should be resolved: %v", format.Name, string(html))
+		}
+	}
+}
+
+func TestRenderArticleOrg(t *testing.T) {
+	content := "* Setup\n[TOC]\n\n** Install\n[[attachment:diagram.png]]\n#+INCLUDE: \"/etc/hostname\"\n"
+
+	html, toc, err := RenderArticle([]byte(content), FormatByExtension(".org"), "test", &Config{BaseURL: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(toc.Headings) != 1 || toc.Headings[0].ID != "setup" || len(toc.Headings[0].Children) != 1 || !toc.Inline {
+		t.Errorf("Table of contents should be built from org headlines: %+v", toc)
+	}
+
+	if !strings.Contains(string(html), `<img srcset=`) || !strings.Contains(string(html), `src="/attachments/test/diagram.png"`) {
+		t.Errorf("Attachment should be displayed as image: %v", string(html))
+	}
+}
@@ -0,0 +1,276 @@
+package models
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alexandria.app/diff"
+)
+
+const revisionLogName = "log.jsonl"
+
+// A Revision is a saved version of an article.
+// The content of every revision is stored alongside the revision log so that changes can be compared later.
+type Revision struct {
+	ID         int64        `json:"id"`
+	Event      string       `json:"event"`
+	Path       string       `json:"path"`
+	OldPath    string       `json:"old_path,omitempty"`
+	AuthorID   uint32       `json:"author_id"`
+	AuthorName string       `json:"author_name"`
+	Timestamp  int64        `json:"timestamp"`
+	Summary    string       `json:"summary"`
+	Diff       diff.Summary `json:"diff"`
+	Format     string       `json:"format,omitempty"`
+}
+
+// Category returns the category the article was in at the time of the revision.
+func (rev *Revision) Category() string {
+	dir := filepath.ToSlash(filepath.Dir(rev.Path))
+	if dir == "." {
+		return ""
+	}
+
+	return dir
+}
+
+// ContentFormat returns the format the article was written in at the time of the revision.
+// Revisions recorded before articles had formats are markdown.
+func (rev *Revision) ContentFormat() *Format {
+	if format := FormatByExtension(rev.Format); format != nil {
+		return format
+	}
+
+	return FormatByExtension(DefaultFormat)
+}
+
+// Time returns the revision's timestamp as time.Time.
+func (rev *Revision) Time() time.Time {
+	return time.Unix(rev.Timestamp, 0)
+}
+
+// RevisionFilter restricts which revisions are returned by RevisionStorage.Recent.
+// Empty fields match all revisions.
+type RevisionFilter struct {
+	Category string
+	AuthorID uint32
+}
+
+func (f RevisionFilter) matches(rev *Revision) bool {
+	if f.AuthorID != 0 && f.AuthorID != rev.AuthorID {
+		return false
+	}
+
+	if len(f.Category) != 0 {
+		category := rev.Category()
+		if category != f.Category && !strings.HasPrefix(category, f.Category+"/") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RevisionStorage keeps track of all changes made to articles.
+// The log of revisions is kept in memory, the content of each revision is only read from disk when needed.
+type RevisionStorage struct {
+	path      string
+	mutex     sync.RWMutex
+	revisions []*Revision
+	lastID    int64
+}
+
+// AddRevision records a new revision for an article and stores its content.
+// The revision's ID and Timestamp will be set by this function.
+func (rs *RevisionStorage) AddRevision(rev *Revision, content []byte) (err error) {
+	defer countStorageError("revisions", &err)
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	now := time.Now()
+
+	// IDs have to be unique and increasing, even if two revisions are created within the same nanosecond.
+	rev.ID = now.UnixNano()
+	if rev.ID <= rs.lastID {
+		rev.ID = rs.lastID + 1
+	}
+	rev.Timestamp = now.Unix()
+
+	contentPath := rs.contentPath(rev)
+	if err := os.MkdirAll(filepath.Dir(contentPath), privateDirPerm); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(contentPath, content, privateFilePerm); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(rs.path, revisionLogName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, privateFilePerm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	rs.revisions = append(rs.revisions, rev)
+	rs.lastID = rev.ID
+
+	return nil
+}
+
+// Recent returns up to limit revisions matching the filter, most recent revision first.
+// A limit <= 0 returns all matching revisions.
+func (rs *RevisionStorage) Recent(filter RevisionFilter, limit int) []*Revision {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	revisions := []*Revision{}
+
+	for i := len(rs.revisions) - 1; i >= 0; i-- {
+		if limit > 0 && len(revisions) >= limit {
+			break
+		}
+
+		if filter.matches(rs.revisions[i]) {
+			revisions = append(revisions, rs.revisions[i])
+		}
+	}
+
+	return revisions
+}
+
+// ForArticle returns all revisions of the article at path, most recent revision first.
+func (rs *RevisionStorage) ForArticle(path string) []*Revision {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	revisions := []*Revision{}
+
+	for i := len(rs.revisions) - 1; i >= 0; i-- {
+		if rs.revisions[i].Path == path {
+			revisions = append(revisions, rs.revisions[i])
+		}
+	}
+
+	return revisions
+}
+
+// GetRevision retrieves a revision of the article at path by its id.
+func (rs *RevisionStorage) GetRevision(path string, id int64) *Revision {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	for _, rev := range rs.revisions {
+		if rev.ID == id && rev.Path == path {
+			return rev
+		}
+	}
+
+	return nil
+}
+
+// GetRevisionByID retrieves a revision of any article by its id.
+func (rs *RevisionStorage) GetRevisionByID(id int64) *Revision {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	for _, rev := range rs.revisions {
+		if rev.ID == id {
+			return rev
+		}
+	}
+
+	return nil
+}
+
+// Previous returns the revision of the same article right before rev.
+// If rev is the article's first revision nil is returned.
+// Moves are followed, so the previous revision may have a different path.
+func (rs *RevisionStorage) Previous(rev *Revision) *Revision {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	path := rev.Path
+	if len(rev.OldPath) != 0 {
+		path = rev.OldPath
+	}
+
+	for i := len(rs.revisions) - 1; i >= 0; i-- {
+		if rs.revisions[i].ID < rev.ID && rs.revisions[i].Path == path {
+			return rs.revisions[i]
+		}
+	}
+
+	return nil
+}
+
+// Content reads the content of the revision from disk.
+func (rs *RevisionStorage) Content(rev *Revision) ([]byte, error) {
+	return ioutil.ReadFile(rs.contentPath(rev))
+}
+
+func (rs *RevisionStorage) contentPath(rev *Revision) string {
+	return filepath.Join(rs.path, filepath.FromSlash(rev.Path), strconv.FormatInt(rev.ID, 10)+".md")
+}
+
+// LoadRevisionStorage reads the revision log from the directory at path.
+// If the directory doesn't exist it will be created.
+func LoadRevisionStorage(path string) (*RevisionStorage, error) {
+	if err := os.MkdirAll(path, privateDirPerm); err != nil {
+		return nil, err
+	}
+
+	rs := &RevisionStorage{
+		path:      path,
+		revisions: []*Revision{},
+	}
+
+	file, err := os.Open(filepath.Join(path, revisionLogName))
+	if os.IsNotExist(err) {
+		return rs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		rev := &Revision{}
+		if err := json.Unmarshal(scanner.Bytes(), rev); err != nil {
+			return nil, err
+		}
+
+		rs.revisions = append(rs.revisions, rev)
+		if rev.ID > rs.lastID {
+			rs.lastID = rev.ID
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
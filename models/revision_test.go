@@ -0,0 +1,73 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestRevisionStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "_TestRevisionStorage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rs, err := LoadRevisionStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := &Revision{Event: EventArticleCreated, Path: "ops/runbook", AuthorID: 1}
+	if err = rs.AddRevision(first, []byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	second := &Revision{Event: EventArticleEdited, Path: "ops/runbook", AuthorID: 2}
+	if err = rs.AddRevision(second, []byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	other := &Revision{Event: EventArticleCreated, Path: "dev/setup", AuthorID: 1}
+	if err = rs.AddRevision(other, []byte("c\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload from disk to make sure the log was persisted.
+	rs, err = LoadRevisionStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recent := rs.Recent(RevisionFilter{}, 2)
+	if len(recent) != 2 || recent[0].ID != other.ID || recent[1].ID != second.ID {
+		t.Errorf("Recent revisions are %v, should be [%v %v]", recent, other, second)
+	}
+
+	if recent := rs.Recent(RevisionFilter{Category: "ops"}, 0); len(recent) != 2 {
+		t.Errorf("Category filter returned %v revisions, should be 2", len(recent))
+	}
+
+	if recent := rs.Recent(RevisionFilter{AuthorID: 1}, 0); len(recent) != 2 {
+		t.Errorf("Author filter returned %v revisions, should be 2", len(recent))
+	}
+
+	rev := rs.GetRevision("ops/runbook", second.ID)
+	if rev == nil {
+		t.Fatal("Revision not found")
+	}
+
+	prev := rs.Previous(rev)
+	if prev == nil || prev.ID != first.ID {
+		t.Fatalf("Previous revision is %v, should be %v", prev, first)
+	}
+
+	content, err := rs.Content(prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "a\n" {
+		t.Errorf(`Content is "%v", should be "a\n"`, string(content))
+	}
+}
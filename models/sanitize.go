@@ -0,0 +1,161 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// droppedElements are removed from rendered content together with everything inside them.
+var droppedElements = map[atom.Atom]bool{
+	atom.Applet:   true,
+	atom.Base:     true,
+	atom.Button:   true,
+	atom.Embed:    true,
+	atom.Form:     true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Iframe:   true,
+	atom.Input:    true,
+	atom.Link:     true,
+	atom.Math:     true,
+	atom.Meta:     true,
+	atom.Noscript: true,
+	atom.Object:   true,
+	atom.Script:   true,
+	atom.Select:   true,
+	atom.Style:    true,
+	atom.Svg:      true,
+	atom.Template: true,
+	atom.Textarea: true,
+	atom.Title:    true,
+}
+
+// allowedElements are kept in rendered content. All other elements are replaced by their content.
+var allowedElements = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.Aside: true, atom.B: true, atom.Blockquote: true, atom.Br: true,
+	atom.Caption: true, atom.Cite: true, atom.Code: true, atom.Col: true, atom.Colgroup: true, atom.Dd: true,
+	atom.Del: true, atom.Details: true, atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Em: true,
+	atom.Figcaption: true, atom.Figure: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.H5: true, atom.H6: true, atom.Hr: true, atom.I: true, atom.Img: true, atom.Ins: true, atom.Kbd: true,
+	atom.Li: true, atom.Mark: true, atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true, atom.Q: true,
+	atom.S: true, atom.Samp: true, atom.Section: true, atom.Small: true, atom.Span: true, atom.Strike: true,
+	atom.Strong: true, atom.Sub: true, atom.Summary: true, atom.Sup: true, atom.Table: true, atom.Tbody: true,
+	atom.Td: true, atom.Tfoot: true, atom.Th: true, atom.Thead: true, atom.Tr: true, atom.U: true, atom.Ul: true,
+	atom.Var: true,
+}
+
+// globalAttributes are allowed on every element.
+var globalAttributes = map[string]bool{
+	"aria-hidden": true,
+	"aria-label":  true,
+	"class":       true,
+	"dir":         true,
+	"id":          true,
+	"lang":        true,
+	"tabindex":    true,
+	"title":       true,
+}
+
+// elementAttributes are allowed on specific elements only.
+var elementAttributes = map[atom.Atom]map[string]bool{
+	atom.A:        {"href": true, "name": true},
+	atom.Col:      {"span": true},
+	atom.Colgroup: {"span": true},
+	atom.Details:  {"open": true},
+	atom.Div:      {"data-language": true},
+	atom.Img:      {"alt": true, "height": true, "src": true, "width": true},
+	atom.Li:       {"value": true},
+	atom.Ol:       {"reversed": true, "start": true, "type": true},
+	atom.Td:       {"align": true, "colspan": true, "rowspan": true},
+	atom.Th:       {"align": true, "colspan": true, "rowspan": true, "scope": true},
+}
+
+// allowedSchemes are the URL schemes links and images may use. Relative URLs are always allowed.
+var allowedSchemes = map[string]bool{
+	"attachment": true,
+	"ftp":        true,
+	"http":       true,
+	"https":      true,
+	"mailto":     true,
+	"tel":        true,
+}
+
+// safeURL checks that the URL can't execute code when it is followed, e.g. "javascript:" URLs.
+func safeURL(val string) bool {
+	u, err := url.Parse(strings.TrimSpace(val))
+	if err != nil {
+		return false
+	}
+
+	return len(u.Scheme) == 0 || allowedSchemes[strings.ToLower(u.Scheme)]
+}
+
+func sanitizeAttributes(n *html.Node) {
+	attrs := n.Attr[:0]
+
+	for _, attr := range n.Attr {
+		if len(attr.Namespace) != 0 {
+			continue
+		}
+
+		if !globalAttributes[attr.Key] && !elementAttributes[n.DataAtom][attr.Key] {
+			continue
+		}
+
+		if (attr.Key == "href" || attr.Key == "src") && !safeURL(attr.Val) {
+			continue
+		}
+
+		attrs = append(attrs, attr)
+	}
+
+	n.Attr = attrs
+}
+
+// sanitize removes everything below n which could run scripts or break the page layout,
+// so that content written by users can be displayed safely. Only known safe elements and attributes are kept.
+func sanitize(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+
+		switch c.Type {
+		case html.ElementNode:
+			// Checkboxes of task lists are the only form elements which are kept. They are disabled so they can't be changed.
+			if c.DataAtom == atom.Input && getAttr(c, "type") == "checkbox" {
+				attrs := []html.Attribute{{Key: "type", Val: "checkbox"}, {Key: "disabled"}}
+				if hasAttr(c, "checked") {
+					attrs = append(attrs, html.Attribute{Key: "checked"})
+				}
+				c.Attr = attrs
+				break
+			}
+
+			if droppedElements[c.DataAtom] {
+				n.RemoveChild(c)
+				break
+			}
+
+			sanitize(c)
+
+			if allowedElements[c.DataAtom] {
+				sanitizeAttributes(c)
+				break
+			}
+
+			for c.FirstChild != nil {
+				child := c.FirstChild
+				c.RemoveChild(child)
+				n.InsertBefore(child, c)
+			}
+			n.RemoveChild(c)
+		case html.TextNode:
+		default:
+			n.RemoveChild(c)
+		}
+
+		c = next
+	}
+}
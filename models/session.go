@@ -1,7 +1,10 @@
 package models
 
 import (
+	"encoding/gob"
+	"io"
 	"net/http"
+	"os"
 	"time"
 
 	"alexandria.app/crypto"
@@ -24,12 +27,12 @@ type Session struct {
 }
 
 // Cookie creates a new cookie that can be passed along with the HTTP response.
-// It is highly recommended to always set isHTTPS to true.
-func (s *Session) Cookie(isHTTPS bool) *http.Cookie {
+// It is highly recommended to always set isHTTPS to true. path limits the cookie to the wiki's base path.
+func (s *Session) Cookie(isHTTPS bool, path string) *http.Cookie {
 	return &http.Cookie{
 		Name:     SessionCookieName,
 		Value:    s.sessionID,
-		Path:     "/",
+		Path:     path,
 		SameSite: http.SameSiteStrictMode,
 		Secure:   isHTTPS,
 		HttpOnly: true,
@@ -49,12 +52,21 @@ func NewSession(u *User) *Session {
 }
 
 // SessionStorage holds all currently active sessions.
-// Note: The storage is not persistent across restarts.
+// Note: The storage is only saved to the file system when the server shuts down, see Save.
 type SessionStorage struct {
 	sessions  []*Session
+	path      string
 	SpawnedAt time.Time
 }
 
+// storedSession is the representation of a session in the session database.
+// Only the user's ID is stored, so that changes to the user are picked up when loading the sessions.
+type storedSession struct {
+	ID        string
+	UserID    uint32
+	CreatedAt time.Time
+}
+
 // AddSession adds a session to the session storage.
 func (sstrg *SessionStorage) AddSession(sess *Session) {
 	sstrg.sessions = append(sstrg.sessions, sess)
@@ -75,11 +87,18 @@ func (sstrg *SessionStorage) RemoveSession(sess *Session) {
 // As a user can have several sessions across multiple devices when deleting a user
 // it is best to invalidate and remove all sessions associated with that user.
 func (sstrg *SessionStorage) RemoveSessionsForUser(id uint32) {
-	for i, s := range sstrg.sessions {
-		if s.User.ID == id {
-			sstrg.sessions = append(sstrg.sessions[:i], sstrg.sessions[i+1:]...)
+	sessions := sstrg.sessions[:0]
+	for _, s := range sstrg.sessions {
+		if s.User.ID != id {
+			sessions = append(sessions, s)
 		}
 	}
+	sstrg.sessions = sessions
+}
+
+// Count returns the number of active sessions.
+func (sstrg *SessionStorage) Count() int {
+	return len(sstrg.sessions)
 }
 
 // GetSession retrieves the session associated with the token from the storage.
@@ -123,12 +142,65 @@ func NewSessionStorage() *SessionStorage {
 	}
 }
 
+// Save encodes the sessions and saves them to the file system, so that users stay logged in across restarts.
+// Storages created by NewSessionStorage aren't saved.
+func (sstrg *SessionStorage) Save() (err error) {
+	defer countStorageError("sessions", &err)
+
+	if len(sstrg.path) == 0 {
+		return nil
+	}
+
+	stored := make([]storedSession, len(sstrg.sessions))
+	for i, s := range sstrg.sessions {
+		stored[i] = storedSession{ID: s.sessionID, UserID: s.User.ID, CreatedAt: s.createdAt}
+	}
+
+	return writeFile(sstrg.path, privateFilePerm, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(stored)
+	})
+}
+
+// LoadSessionStorage loads the sessions saved at the provided path or creates an empty storage if there are none.
+// Sessions of users which no longer exist and sessions whose cookie has expired are dropped.
+func LoadSessionStorage(path string, userStorage UserStorage) (*SessionStorage, error) {
+	sstrg := NewSessionStorage()
+	sstrg.path = path
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return sstrg, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stored := []storedSession{}
+	if err = gob.NewDecoder(file).Decode(&stored); err != nil {
+		return nil, err
+	}
+
+	expired := time.Now().Add(-cookieDuration * 24 * time.Hour)
+	for _, s := range stored {
+		user := userStorage.GetUserByID(s.UserID)
+		if user == nil || s.CreatedAt.Before(expired) {
+			continue
+		}
+
+		sstrg.AddSession(&Session{User: user, sessionID: s.ID, createdAt: s.CreatedAt})
+	}
+
+	return sstrg, nil
+}
+
 // RemoveSessionCookie creates a new cookie with the same name as the session cookie
 // with an expire date so far in the past that devices will delete the cookie thus invalidating the session.
-func RemoveSessionCookie(isHTTPS bool) *http.Cookie {
+// The path has to be the one the cookie was set with.
+func RemoveSessionCookie(isHTTPS bool, path string) *http.Cookie {
 	return &http.Cookie{
 		Name:     SessionCookieName,
 		Value:    "",
+		Path:     path,
 		SameSite: http.SameSiteStrictMode,
 		Secure:   isHTTPS,
 		HttpOnly: true,
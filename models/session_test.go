@@ -0,0 +1,57 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveLoadSessions(t *testing.T) {
+	testStoragePath := filepath.Join(os.TempDir(), "_TestSaveLoadSessions.db")
+	defer removeTestDB(testStoragePath)
+
+	ustr, err := LoadUserStorage(filepath.Join(os.TempDir(), "_TestSaveLoadSessionsUsers.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := NewUser("test@example.com", "Not Bob", "123456789", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ustr.AddUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	sstr, err := LoadSessionStorage(testStoragePath, ustr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := NewSession(user)
+	expired := NewSession(user)
+	expired.createdAt = time.Now().Add(-(cookieDuration + 1) * 24 * time.Hour)
+	deleted := NewSession(&User{ID: user.ID + 1})
+
+	sstr.AddSession(session)
+	sstr.AddSession(expired)
+	sstr.AddSession(deleted)
+
+	if err = sstr.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadSessionStorage(testStoragePath, ustr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := loaded.GetSession(session.sessionID); s == nil || s.User != ustr.GetUserByID(user.ID) {
+		t.Errorf("Session should be loaded with its user, got %+v", s)
+	}
+
+	if loaded.GetSession(expired.sessionID) != nil || loaded.GetSession(deleted.sessionID) != nil {
+		t.Error("Expired sessions and sessions of deleted users should be dropped")
+	}
+}
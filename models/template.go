@@ -0,0 +1,142 @@
+package models
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// CategorySettingsFile is stored in a category's directory and holds the category's settings.
+const CategorySettingsFile = ".category.toml"
+
+// CategorySettings are the settings of a category, stored in its CategorySettingsFile.
+type CategorySettings struct {
+	// Template is the name of the template new articles in the category start from.
+	Template string
+}
+
+// TemplateValues are filled in for the placeholders in an article template.
+// Values which are empty leave their placeholder untouched.
+type TemplateValues struct {
+	Title  string
+	Author string
+	Date   time.Time
+}
+
+var placeholderRegexp = regexp.MustCompile(`\{\{\s*(title|author|date)\s*\}\}`)
+
+// ExpandTemplate replaces the placeholders {{title}}, {{author}} and {{date}} in content with the values.
+func ExpandTemplate(content []byte, values TemplateValues) []byte {
+	return placeholderRegexp.ReplaceAllFunc(content, func(placeholder []byte) []byte {
+		val := ""
+		switch string(placeholderRegexp.FindSubmatch(placeholder)[1]) {
+		case "title":
+			val = values.Title
+		case "author":
+			val = values.Author
+		case "date":
+			if !values.Date.IsZero() {
+				val = values.Date.Format("2006-01-02")
+			}
+		}
+
+		if len(val) == 0 {
+			return placeholder
+		}
+
+		return []byte(val)
+	})
+}
+
+// IsArticleTemplate checks if the article with the name is stored in the category for templates.
+func IsArticleTemplate(config *Config, name string) bool {
+	return len(config.ArticleTemplates) != 0 && strings.HasPrefix(name+"/", config.ArticleTemplates+"/")
+}
+
+// ListArticleTemplates returns the names of all templates, without the template category.
+func ListArticleTemplates(config *Config) ([]string, error) {
+	if len(config.ArticleTemplates) == 0 {
+		return []string{}, nil
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(config.ContentPath, config.ArticleTemplates))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	templates := make([]string, 0, len(files))
+	for _, file := range files {
+		if !file.IsDir() && IsArticleFile(file.Name()) {
+			templates = append(templates, strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())))
+		}
+	}
+
+	sort.Strings(templates)
+
+	return templates, nil
+}
+
+// LoadArticleTemplate loads the template with the name from the category for templates.
+func LoadArticleTemplate(config *Config, name string) (*Article, error) {
+	if len(config.ArticleTemplates) == 0 || !ValidArticleName(name) || strings.Contains(name, "/") {
+		return nil, &os.PathError{Op: "load", Path: name, Err: os.ErrNotExist}
+	}
+
+	path, err := FindArticle(filepath.Join(config.ContentPath, config.ArticleTemplates, name))
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadArticle(path)
+}
+
+// LoadCategorySettings reads the settings of the category with the name.
+// Categories without a settings file have the default settings.
+func LoadCategorySettings(config *Config, name string) (*CategorySettings, error) {
+	settings := &CategorySettings{}
+
+	_, err := toml.DecodeFile(filepath.Join(config.ContentPath, filepath.FromSlash(name), CategorySettingsFile), settings)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// SaveCategorySettings writes the settings of the category with the name.
+func SaveCategorySettings(config *Config, name string, settings *CategorySettings) error {
+	path := filepath.Join(config.ContentPath, filepath.FromSlash(name), CategorySettingsFile)
+
+	return writeFile(path, filePerm, func(w io.Writer) error {
+		return toml.NewEncoder(w).Encode(settings)
+	})
+}
+
+// DefaultTemplate returns the template for new articles in the category with the name.
+// Categories without a template use the template of their closest parent category that has one.
+func DefaultTemplate(config *Config, name string) (string, error) {
+	for name = strings.Trim(name, "/"); ; name = path.Dir(name) {
+		settings, err := LoadCategorySettings(config, name)
+		if err != nil {
+			return "", err
+		}
+
+		if len(settings.Template) != 0 {
+			return settings.Template, nil
+		}
+
+		if name == "." || len(name) == 0 {
+			return "", nil
+		}
+	}
+}
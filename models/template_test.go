@@ -0,0 +1,82 @@
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	content := "# {{title}}\n\nBy {{ author }} on {{date}}, {{unknown}}\n"
+	date := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	expanded := string(ExpandTemplate([]byte(content), TemplateValues{Author: "Jane", Date: date}))
+	expected := "# {{title}}\n\nBy Jane on 2020-03-01, {{unknown}}\n"
+	if expanded != expected {
+		t.Errorf("Expanded template is %q, should be %q", expanded, expected)
+	}
+
+	expanded = string(ExpandTemplate([]byte(expanded), TemplateValues{Title: "Outage"}))
+	if !strings.HasPrefix(expanded, "# Outage\n") {
+		t.Errorf("Title should be filled in later: %q", expanded)
+	}
+}
+
+func TestArticleTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{ContentPath: dir, ArticleTemplates: "templates"}
+
+	for _, name := range []string{"Runbook", "Postmortem"} {
+		if err = NewArticle(name, "# {{title}}", filepath.Join(dir, "templates"), ".md").Write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	templates, err := ListArticleTemplates(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(templates, ",") != "Postmortem,Runbook" {
+		t.Errorf("Templates are %v, should be [Postmortem Runbook]", templates)
+	}
+
+	if _, err = LoadArticleTemplate(config, "../templates/Runbook"); err == nil {
+		t.Error("Templates outside the template category should not be loaded")
+	}
+
+	if !IsArticleTemplate(config, "templates/Runbook") || IsArticleTemplate(config, "templates-old/Runbook") {
+		t.Error("Only articles in the template category should be templates")
+	}
+
+	if err = os.MkdirAll(filepath.Join(dir, "services", "web"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = SaveCategorySettings(config, "services", &CategorySettings{Template: "Runbook"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, category := range []string{"services", "services/web"} {
+		template, err := DefaultTemplate(config, category)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if template != "Runbook" {
+			t.Errorf("Default template of %v is %q, should be Runbook", category, template)
+		}
+	}
+
+	if template, _ := DefaultTemplate(config, "templates"); len(template) != 0 {
+		t.Errorf("Category without settings should have no default template, got %q", template)
+	}
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Registers the GIF decoder for image.Decode.
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"alexandria.app/imaging"
+)
+
+// ThumbnailWidths are the widths of all variants which are generated for image attachments.
+// Requested sizes are rounded up to the next variant so that the cache can't grow indefinitely.
+var ThumbnailWidths = []int{320, 640, 1280, 1920}
+
+const thumbnailJPEGQuality = 85
+
+// maxThumbnailPixels is the size of the largest image thumbnails are generated for. Decoding and resizing need
+// several copies of the image in memory, so a small file which decodes to a huge image could exhaust it.
+var maxThumbnailPixels = 50 * 1000 * 1000
+
+// ErrImageTooLarge is returned by Thumbnail if the image has more than maxThumbnailPixels pixels.
+var ErrImageTooLarge = errors.New("image too large to be resized")
+
+// thumbnailLocks holds a mutex for every thumbnail which is currently generated, so that concurrent requests
+// for the same variant generate it only once.
+var thumbnailLocks = struct {
+	sync.Mutex
+	paths map[string]*thumbnailLock
+}{paths: map[string]*thumbnailLock{}}
+
+type thumbnailLock struct {
+	sync.Mutex
+	waiting int
+}
+
+// lockThumbnail locks the thumbnail at path and returns the function unlocking it.
+func lockThumbnail(path string) func() {
+	thumbnailLocks.Lock()
+	l, ok := thumbnailLocks.paths[path]
+	if !ok {
+		l = &thumbnailLock{}
+		thumbnailLocks.paths[path] = l
+	}
+	l.waiting++
+	thumbnailLocks.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		thumbnailLocks.Lock()
+		if l.waiting--; l.waiting == 0 {
+			delete(thumbnailLocks.paths, path)
+		}
+		thumbnailLocks.Unlock()
+	}
+}
+
+// CanResize checks if variants of the attachment can be generated.
+func (a *Attachment) CanResize() bool {
+	return resizableImage(a.Name)
+}
+
+func resizableImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// ThumbnailWidth rounds the requested width up to the next available variant.
+// If the width is larger than every variant 0 is returned, meaning the original should be used.
+func ThumbnailWidth(width int) int {
+	for _, w := range ThumbnailWidths {
+		if width <= w {
+			return w
+		}
+	}
+
+	return 0
+}
+
+// Thumbnail returns the variant of the attachment with the given width, which has to be one of ThumbnailWidths.
+// Variants are generated on first use and cached below cacheDir. They are regenerated when the attachment changes.
+// If the attachment is smaller than the requested width the attachment itself is returned, if it is larger than
+// maxThumbnailPixels ErrImageTooLarge.
+func Thumbnail(attachment *Attachment, articleName string, width int, cacheDir string) (*Attachment, error) {
+	ext := strings.ToLower(filepath.Ext(attachment.Name))
+	if ext == ".gif" {
+		// Thumbnails of GIFs only contain the first frame, so they are stored as PNG.
+		ext = ".png"
+	}
+
+	// The name includes the original's extension, so that e.g. "logo.gif" and "logo.png" get different variants.
+	name := fmt.Sprintf("%s@%d%s", attachment.Name, width, ext)
+	dir := filepath.Join(cacheDir, "thumbnails", filepath.FromSlash(articleName))
+	path := filepath.Join(dir, name)
+
+	if thumbnail := cachedThumbnail(attachment, name, path); thumbnail != nil {
+		return thumbnail, nil
+	}
+
+	unlock := lockThumbnail(path)
+	defer unlock()
+
+	// Another request may have generated the thumbnail while this one waited for the lock.
+	if thumbnail := cachedThumbnail(attachment, name, path); thumbnail != nil {
+		return thumbnail, nil
+	}
+
+	file, err := os.Open(attachment.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width <= width {
+		return attachment, nil
+	}
+
+	if config.Width*config.Height > maxThumbnailPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	if _, err = file.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnail := imaging.Resize(img, width)
+
+	if err = os.MkdirAll(dir, privateDirPerm); err != nil {
+		return nil, err
+	}
+
+	// Requests which found the thumbnail outdated may still read it, so it is written to a temporary file first.
+	tmp, err := ioutil.TempFile(dir, ".thumbnail-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if ext == ".png" {
+		err = png.Encode(tmp, thumbnail)
+	} else {
+		err = jpeg.Encode(tmp, thumbnail, &jpeg.Options{Quality: thumbnailJPEGQuality})
+	}
+
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Attachment{Name: name, Path: path, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+// cachedThumbnail returns the thumbnail with the name at path if it was generated after the attachment changed.
+func cachedThumbnail(attachment *Attachment, name, path string) *Attachment {
+	stat, err := os.Stat(path)
+	if err != nil || stat.ModTime().Before(attachment.ModTime) {
+		return nil
+	}
+
+	return &Attachment{Name: name, Path: path, Size: stat.Size(), ModTime: stat.ModTime()}
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alexandria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img := image.NewPaletted(image.Rect(0, 0, 800, 400), color.Palette{color.White, color.Black})
+
+	pngFile, err := os.Create(filepath.Join(dir, "logo.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = png.Encode(pngFile, img); err != nil {
+		t.Fatal(err)
+	}
+	pngFile.Close()
+
+	gifFile, err := os.Create(filepath.Join(dir, "logo.gif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = gif.Encode(gifFile, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	gifFile.Close()
+
+	cacheDir := filepath.Join(dir, "cache")
+	paths := map[string]bool{}
+	for _, name := range []string{"logo.png", "logo.gif"} {
+		attachment, err := LoadAttachment(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Concurrent requests share the generated thumbnail.
+		var wg sync.WaitGroup
+		thumbnails := make([]*Attachment, 4)
+		errs := make([]error, len(thumbnails))
+		for i := range thumbnails {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				thumbnails[i], errs[i] = Thumbnail(attachment, "article", 320, cacheDir)
+			}(i)
+		}
+		wg.Wait()
+
+		for i, thumbnail := range thumbnails {
+			if errs[i] != nil {
+				t.Fatal(errs[i])
+			}
+			if thumbnail.Path != thumbnails[0].Path {
+				t.Errorf("Concurrent requests returned different thumbnails: %v and %v", thumbnail.Path, thumbnails[0].Path)
+			}
+		}
+		paths[thumbnails[0].Path] = true
+	}
+
+	if len(paths) != 2 {
+		t.Errorf("Attachments which only differ in their extension should have different thumbnails, got %v", paths)
+	}
+
+	maxPixels := maxThumbnailPixels
+	maxThumbnailPixels = 100 * 100
+	defer func() { maxThumbnailPixels = maxPixels }()
+
+	attachment, err := LoadAttachment(dir, "logo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Thumbnail(attachment, "article", 640, cacheDir); err != ErrImageTooLarge {
+		t.Errorf("Images with more than maxThumbnailPixels pixels shouldn't be resized, got %v", err)
+	}
+}
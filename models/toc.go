@@ -0,0 +1,210 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shurcooL/sanitized_anchor_name"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// TOCMarker can be put on a line of its own to place the table of contents inside the article.
+const TOCMarker = "[TOC]"
+
+// A Heading is an entry in an article's table of contents.
+type Heading struct {
+	ID       string
+	Title    string
+	Level    int
+	Children []*Heading
+}
+
+// TableOfContents lists all headings of an article, nested by their level.
+type TableOfContents struct {
+	Headings []*Heading
+	// Inline is true if the article contains the TOCMarker, meaning the table of contents is already part of the content.
+	Inline bool
+}
+
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1,
+	atom.H2: 2,
+	atom.H3: 3,
+	atom.H4: 4,
+	atom.H5: 5,
+	atom.H6: 6,
+}
+
+// textContent returns the plain text of the node, without any markup.
+func textContent(n *html.Node) string {
+	var b strings.Builder
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// isTOCMarker checks if the node is a paragraph which only consists of the TOCMarker.
+func isTOCMarker(n *html.Node) bool {
+	if n.Type != html.ElementNode || n.DataAtom != atom.P {
+		return false
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.TextNode {
+			return false
+		}
+	}
+
+	return textContent(n) == TOCMarker
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i, attr := range n.Attr {
+		if attr.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func newElement(a atom.Atom, attrs ...html.Attribute) *html.Node {
+	return &html.Node{Type: html.ElementNode, DataAtom: a, Data: a.String(), Attr: attrs}
+}
+
+// buildTOC collects all headings below root and assigns every heading a unique, stable ID and a permalink.
+// Headings with the same text are numbered in the order in which they appear, e.g. "setup", "setup-1".
+// Paragraphs consisting of the TOCMarker are replaced by the table of contents.
+func buildTOC(root *html.Node) *TableOfContents {
+	toc := &TableOfContents{Headings: []*Heading{}}
+	used := map[string]bool{}
+	stack := []*Heading{}
+	markers := []*html.Node{}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if isTOCMarker(c) {
+				markers = append(markers, c)
+				continue
+			}
+
+			level, ok := headingLevels[c.DataAtom]
+			if c.Type != html.ElementNode || !ok {
+				walk(c)
+				continue
+			}
+
+			title := textContent(c)
+
+			base := getAttr(c, "id")
+			if len(base) == 0 {
+				base = sanitized_anchor_name.Create(title)
+			}
+			if len(base) == 0 {
+				base = "section"
+			}
+
+			id := base
+			for n := 1; used[id]; n++ {
+				id = fmt.Sprintf("%s-%d", base, n)
+			}
+			used[id] = true
+
+			setAttr(c, "id", id)
+
+			anchor := newElement(atom.A,
+				html.Attribute{Key: "class", Val: "heading-anchor"},
+				html.Attribute{Key: "href", Val: "#" + id},
+				html.Attribute{Key: "aria-label", Val: "Permalink"},
+			)
+			anchor.AppendChild(&html.Node{Type: html.TextNode, Data: "#"})
+			c.AppendChild(anchor)
+
+			heading := &Heading{ID: id, Title: title, Level: level, Children: []*Heading{}}
+
+			for len(stack) != 0 && stack[len(stack)-1].Level >= heading.Level {
+				stack = stack[:len(stack)-1]
+			}
+
+			if len(stack) == 0 {
+				toc.Headings = append(toc.Headings, heading)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, heading)
+			}
+
+			stack = append(stack, heading)
+		}
+	}
+	walk(root)
+
+	for _, marker := range markers {
+		marker.Parent.InsertBefore(toc.node(), marker)
+		marker.Parent.RemoveChild(marker)
+	}
+	toc.Inline = len(markers) != 0
+
+	return toc
+}
+
+func headingsNode(headings []*Heading) *html.Node {
+	list := newElement(atom.Ul)
+
+	for _, h := range headings {
+		link := newElement(atom.A, html.Attribute{Key: "href", Val: "#" + h.ID})
+		link.AppendChild(&html.Node{Type: html.TextNode, Data: h.Title})
+
+		item := newElement(atom.Li)
+		item.AppendChild(link)
+		if len(h.Children) != 0 {
+			item.AppendChild(headingsNode(h.Children))
+		}
+
+		list.AppendChild(item)
+	}
+
+	return list
+}
+
+// node renders the table of contents as nested lists.
+func (toc *TableOfContents) node() *html.Node {
+	nav := newElement(atom.Nav, html.Attribute{Key: "class", Val: "toc"})
+	if len(toc.Headings) != 0 {
+		nav.AppendChild(headingsNode(toc.Headings))
+	}
+
+	return nav
+}
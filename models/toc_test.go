@@ -0,0 +1,53 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableOfContents(t *testing.T) {
+	content := "# Setup\n\n## Install\n\n## Configure\n\n### Advanced\n\n# Setup\n"
+
+	_, toc, err := RenderArticle([]byte(content), FormatByExtension(".md"), "test", &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if toc.Inline {
+		t.Error("Table of contents should not be inline")
+	}
+
+	if len(toc.Headings) != 2 {
+		t.Fatalf("Table of contents has %v top level headings, should have 2", len(toc.Headings))
+	}
+
+	if toc.Headings[0].ID != "setup" || toc.Headings[1].ID != "setup-1" {
+		t.Errorf("Heading IDs are %v and %v, should be setup and setup-1", toc.Headings[0].ID, toc.Headings[1].ID)
+	}
+
+	children := toc.Headings[0].Children
+	if len(children) != 2 || children[1].Title != "Configure" || len(children[1].Children) != 1 {
+		t.Errorf("Headings are not nested correctly: %+v", children)
+	}
+}
+
+func TestTableOfContentsInline(t *testing.T) {
+	content := "[TOC]\n\n# First\n\n# Second\n"
+
+	html, toc, err := RenderArticle([]byte(content), FormatByExtension(".md"), "test", &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !toc.Inline {
+		t.Error("Table of contents should be inline")
+	}
+
+	if strings.Contains(string(html), TOCMarker) {
+		t.Error("Marker should be replaced")
+	}
+
+	if !strings.Contains(string(html), `<nav class="toc">`) || !strings.Contains(string(html), `<a href="#second">Second</a>`) {
+		t.Errorf("HTML should contain the table of contents: %v", string(html))
+	}
+}
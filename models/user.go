@@ -1,15 +1,15 @@
 package models
 
 import (
+	"crypto/subtle"
 	"encoding/gob"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
 
-	"golang.org/x/crypto/argon2"
-
 	"github.com/google/uuid"
 
 	"alexandria.app/crypto"
@@ -29,6 +29,21 @@ type User struct {
 	Argon2Threads uint8
 	Argon2Time    uint32
 	Argon2Version int
+	FeedToken     string
+}
+
+const feedTokenLength = 32
+
+// ResetFeedToken generates a new random token which grants access to the wiki's feeds.
+// Note: This doesn't save the database to the file system!
+func (u *User) ResetFeedToken() error {
+	token, err := crypto.GetRandomString(feedTokenLength)
+	if err != nil {
+		return err
+	}
+
+	u.FeedToken = token
+	return nil
 }
 
 // Following https://tools.ietf.org/html/draft-irtf-cfrg-argon2-03#section-4
@@ -48,7 +63,12 @@ func NewUser(email, displayName, password string, admin bool) (*User, error) {
 		return nil, err
 	}
 
-	tempPasswd := argon2.IDKey([]byte(password), []byte(salt), argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	feedToken, err := crypto.GetRandomString(feedTokenLength)
+	if err != nil {
+		return nil, err
+	}
+
+	tempPasswd := hashPassword(password, salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
 
 	return &User{
 		Admin:         admin,
@@ -62,12 +82,16 @@ func NewUser(email, displayName, password string, admin bool) (*User, error) {
 		Argon2Threads: argon2Threads,
 		Argon2Time:    argon2Time,
 		Argon2Version: argon2Version,
+		FeedToken:     feedToken,
 	}, nil
 }
 
 // UserStorage is a presistent database of all users in the system.
 type UserStorage interface {
 	GetUsers() []*User
+	GetUser(email string) *User
+	GetUserByID(id uint32) *User
+	GetUserByFeedToken(token string) *User
 	AddUser(*User) error
 	DeleteUser(id uint32)
 	Save() error
@@ -112,6 +136,22 @@ func (udb *userStorage) GetUserByID(id uint32) *User {
 	return nil
 }
 
+// GetUserByFeedToken retrieves a user by their feed token.
+// Performs a simple linear search.
+func (udb *userStorage) GetUserByFeedToken(token string) *User {
+	if len(token) == 0 {
+		return nil
+	}
+
+	for _, u := range udb.Users {
+		if subtle.ConstantTimeCompare([]byte(u.FeedToken), []byte(token)) == 1 {
+			return u
+		}
+	}
+
+	return nil
+}
+
 // AddUser inserts a new user into the database.
 // Note: This doesn't save the database to the file system!
 func (udb *userStorage) AddUser(newUser *User) error {
@@ -154,7 +194,7 @@ func (udb *userStorage) CheckUserPassword(user *User, password string) *User {
 		return nil
 	}
 
-	tempPasswd := argon2.IDKey([]byte(password), []byte(user.Salt), user.Argon2Time, user.Argon2Memory, user.Argon2Threads, user.Argon2KeyLen)
+	tempPasswd := hashPassword(password, user.Salt, user.Argon2Time, user.Argon2Memory, user.Argon2Threads, user.Argon2KeyLen)
 
 	if user.Password != fmt.Sprintf("%x", tempPasswd) {
 		return nil
@@ -172,7 +212,7 @@ func (udb *userStorage) SetUserPassword(user *User, newPassword string) error {
 		return err
 	}
 
-	tempPasswd := argon2.IDKey([]byte(newPassword), []byte(salt), argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	tempPasswd := hashPassword(newPassword, salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
 
 	user.Password = fmt.Sprintf("%x", tempPasswd)
 	user.Salt = salt
@@ -186,19 +226,15 @@ func (udb *userStorage) SetUserPassword(user *User, newPassword string) error {
 }
 
 // Save will encode the database and save it to the file system.
-func (udb *userStorage) Save() error {
+func (udb *userStorage) Save() (err error) {
+	defer countStorageError("users", &err)
+
 	gob.Register(User{})
 	gob.Register(userStorage{})
 
-	file, err := os.OpenFile(udb.path, os.O_RDWR|os.O_CREATE, os.ModePerm)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	enc := gob.NewEncoder(file)
-
-	return enc.Encode(udb)
+	return writeFile(udb.path, privateFilePerm, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(udb)
+	})
 }
 
 // IsEmpty checks if the user database is empty. This should only be the case when initially setting up the system.
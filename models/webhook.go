@@ -0,0 +1,374 @@
+package models
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"alexandria.app/diff"
+)
+
+// All events which can be sent to a webhook.
+const (
+	EventArticleCreated = "article.created"
+	EventArticleEdited  = "article.edited"
+	EventArticleMoved   = "article.moved"
+	EventArticleDeleted = "article.deleted"
+	EventUserAdded      = "user.added"
+	EventUserRemoved    = "user.removed"
+)
+
+// Events lists all events in the order in which they should be presented to the user.
+var Events = []string{
+	EventArticleCreated,
+	EventArticleEdited,
+	EventArticleMoved,
+	EventArticleDeleted,
+	EventUserAdded,
+	EventUserRemoved,
+}
+
+const (
+	// WebhookSignatureHeader contains the hex encoded HMAC-SHA256 of the request body, signed with the webhook's secret.
+	WebhookSignatureHeader = "X-Alexandria-Signature"
+	// WebhookEventHeader contains the name of the event which triggered the delivery.
+	WebhookEventHeader = "X-Alexandria-Event"
+	// WebhookDeliveryHeader contains the unique id of the delivery. It stays the same across retries.
+	WebhookDeliveryHeader = "X-Alexandria-Delivery"
+
+	webhookMaxAttempts   = 5
+	webhookBackoff       = 2 * time.Second
+	webhookTimeout       = 10 * time.Second
+	webhookDeliveryLimit = 100
+)
+
+// EventAuthor is the user who caused an event.
+type EventAuthor struct {
+	ID          uint32 `json:"id"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+}
+
+// ArticleEvent contains the details of an event concerning an article.
+type ArticleEvent struct {
+	Path    string       `json:"path"`
+	OldPath string       `json:"old_path,omitempty"`
+	Title   string       `json:"title"`
+	Diff    diff.Summary `json:"diff"`
+}
+
+// UserEvent contains the details of an event concerning a user.
+type UserEvent struct {
+	ID          uint32 `json:"id"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Admin       bool   `json:"admin"`
+}
+
+// An Event is the payload which gets sent to every webhook subscribed to its type.
+type Event struct {
+	Type      string        `json:"event"`
+	Timestamp int64         `json:"timestamp"`
+	Author    *EventAuthor  `json:"author,omitempty"`
+	Article   *ArticleEvent `json:"article,omitempty"`
+	User      *UserEvent    `json:"user,omitempty"`
+}
+
+func newEventAuthor(u *User) *EventAuthor {
+	if u == nil {
+		return nil
+	}
+
+	return &EventAuthor{
+		ID:          u.ID,
+		Email:       u.Email,
+		DisplayName: u.DisplayName,
+	}
+}
+
+// NewArticleEvent creates an event for an article change.
+func NewArticleEvent(eventType string, author *User, path, oldPath, title string, changes diff.Summary) *Event {
+	return &Event{
+		Type:      eventType,
+		Timestamp: time.Now().Unix(),
+		Author:    newEventAuthor(author),
+		Article: &ArticleEvent{
+			Path:    path,
+			OldPath: oldPath,
+			Title:   title,
+			Diff:    changes,
+		},
+	}
+}
+
+// NewUserEvent creates an event for a user being added or removed.
+func NewUserEvent(eventType string, author, user *User) *Event {
+	return &Event{
+		Type:      eventType,
+		Timestamp: time.Now().Unix(),
+		Author:    newEventAuthor(author),
+		User: &UserEvent{
+			ID:          user.ID,
+			Email:       user.Email,
+			DisplayName: user.DisplayName,
+			Admin:       user.Admin,
+		},
+	}
+}
+
+// A Webhook is an URL which will be notified about events happening in the wiki.
+type Webhook struct {
+	ID           uint32
+	URL          string
+	Events       []string
+	Secret       string
+	CreationDate int64
+}
+
+// Subscribed checks if the webhook wants to receive the event.
+// A webhook without any events is subscribed to all of them.
+func (wh *Webhook) Subscribed(eventType string) bool {
+	if len(wh.Events) == 0 {
+		return true
+	}
+
+	for _, e := range wh.Events {
+		if e == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sign creates the signature for the payload using the webhook's secret.
+func (wh *Webhook) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(wh.Secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// A WebhookDelivery records the outcome of sending an event to a webhook.
+type WebhookDelivery struct {
+	ID         string
+	WebhookID  uint32
+	URL        string
+	Event      string
+	CreatedAt  time.Time
+	Attempts   int
+	StatusCode int
+	Error      string
+	Done       bool
+}
+
+// Succeeded returns true when the webhook accepted the delivery.
+func (d WebhookDelivery) Succeeded() bool {
+	return d.Done && len(d.Error) == 0
+}
+
+// WebhookStorage is a persistent database of all registered webhooks.
+// The delivery log is kept in memory only and is limited to the most recent deliveries.
+type WebhookStorage struct {
+	Version    int
+	Webhooks   []*Webhook
+	path       string
+	mutex      sync.RWMutex
+	deliveries []*WebhookDelivery
+	client     *http.Client
+	backoff    time.Duration
+}
+
+// GetWebhooks returns a slice of all webhooks.
+func (ws *WebhookStorage) GetWebhooks() []*Webhook {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+
+	webhooks := make([]*Webhook, len(ws.Webhooks))
+	copy(webhooks, ws.Webhooks)
+	return webhooks
+}
+
+// AddWebhook inserts a new webhook into the database.
+// Note: This doesn't save the database to the file system!
+func (ws *WebhookStorage) AddWebhook(wh *Webhook) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	wh.ID = uuid.New().ID()
+	wh.CreationDate = time.Now().Unix()
+	ws.Webhooks = append(ws.Webhooks, wh)
+}
+
+// DeleteWebhook deletes a webhook from the database.
+// Note: This doesn't save the database to the file system!
+func (ws *WebhookStorage) DeleteWebhook(id uint32) {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	for i, wh := range ws.Webhooks {
+		if wh.ID == id {
+			ws.Webhooks = append(ws.Webhooks[:i], ws.Webhooks[i+1:]...)
+			return
+		}
+	}
+}
+
+// Save will encode the database and save it to the file system.
+func (ws *WebhookStorage) Save() (err error) {
+	defer countStorageError("webhooks", &err)
+
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+
+	gob.Register(Webhook{})
+
+	return writeFile(ws.path, privateFilePerm, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(ws)
+	})
+}
+
+// Deliveries returns a copy of the delivery log, most recent delivery first.
+func (ws *WebhookStorage) Deliveries() []WebhookDelivery {
+	ws.mutex.RLock()
+	defer ws.mutex.RUnlock()
+
+	deliveries := make([]WebhookDelivery, len(ws.deliveries))
+	for i, d := range ws.deliveries {
+		deliveries[len(deliveries)-1-i] = *d
+	}
+
+	return deliveries
+}
+
+// Dispatch sends the event to all subscribed webhooks.
+// The deliveries happen asynchronously, failed deliveries are retried with an exponential backoff.
+func (ws *WebhookStorage) Dispatch(event *Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	for _, wh := range ws.GetWebhooks() {
+		if !wh.Subscribed(event.Type) {
+			continue
+		}
+
+		delivery := &WebhookDelivery{
+			ID:        uuid.New().String(),
+			WebhookID: wh.ID,
+			URL:       wh.URL,
+			Event:     event.Type,
+			CreatedAt: time.Now(),
+		}
+
+		ws.mutex.Lock()
+		ws.deliveries = append(ws.deliveries, delivery)
+		if len(ws.deliveries) > webhookDeliveryLimit {
+			ws.deliveries = ws.deliveries[len(ws.deliveries)-webhookDeliveryLimit:]
+		}
+		ws.mutex.Unlock()
+
+		go ws.deliver(wh, delivery, payload)
+	}
+}
+
+func (ws *WebhookStorage) deliver(wh *Webhook, delivery *WebhookDelivery, payload []byte) {
+	backoff := ws.backoff
+
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		statusCode, err := ws.send(wh, delivery, payload)
+		// There is no point in waiting after the last attempt.
+		done := err == nil || attempt == webhookMaxAttempts
+
+		ws.mutex.Lock()
+		delivery.Attempts = attempt
+		delivery.StatusCode = statusCode
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.Error = ""
+		}
+		delivery.Done = done
+		ws.mutex.Unlock()
+
+		if done {
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (ws *WebhookStorage) send(wh *Webhook, delivery *WebhookDelivery, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Alexandria-Webhook")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookSignatureHeader, wh.Sign(payload))
+
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("Unexpected status code %v", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// LoadWebhookStorage loads the database from the file system or creates a new database if it can't find one at the provided path.
+// Note: This doesn't save the database to the file system when creating it!
+func LoadWebhookStorage(path string) (*WebhookStorage, error) {
+	ws := &WebhookStorage{
+		Version:  1,
+		path:     path,
+		Webhooks: []*Webhook{},
+		client:   &http.Client{Timeout: webhookTimeout},
+		backoff:  webhookBackoff,
+	}
+
+	_, err := os.Stat(path)
+
+	if os.IsNotExist(err) {
+		return ws, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gob.Register(Webhook{})
+
+	dec := gob.NewDecoder(file)
+
+	err = dec.Decode(ws)
+	if err != nil {
+		return nil, err
+	}
+
+	return ws, nil
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"alexandria.app/diff"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	testStoragePath := filepath.Join(os.TempDir(), "_TestWebhookDelivery.db")
+	defer removeTestDB(testStoragePath)
+
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first attempt to force a retry.
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	ws, err := LoadWebhookStorage(testStoragePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.backoff = time.Millisecond
+
+	wh := &Webhook{URL: srv.URL, Secret: "s3cr3t", Events: []string{EventArticleCreated}}
+	ws.AddWebhook(wh)
+
+	if err = ws.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err = LoadWebhookStorage(testStoragePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.backoff = time.Millisecond
+
+	if len(ws.GetWebhooks()) != 1 {
+		t.Fatalf("Webhook storage has %v webhooks, should have 1", len(ws.GetWebhooks()))
+	}
+
+	// Not subscribed, should not be delivered.
+	ws.Dispatch(NewArticleEvent(EventArticleDeleted, nil, "foo/bar", "", "bar", diff.Summary{Removed: 1}))
+	ws.Dispatch(NewArticleEvent(EventArticleCreated, nil, "foo/bar", "", "bar", diff.Summary{Added: 2}))
+
+	select {
+	case r := <-requests:
+		body := <-bodies
+
+		if r.Header.Get(WebhookEventHeader) != EventArticleCreated {
+			t.Errorf(`Event header is "%v", should be "%v"`, r.Header.Get(WebhookEventHeader), EventArticleCreated)
+		}
+
+		if r.Header.Get(WebhookSignatureHeader) != wh.Sign(body) {
+			t.Errorf("Signature doesn't match payload")
+		}
+
+		event := Event{}
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Fatal(err)
+		}
+
+		if event.Article.Path != "foo/bar" || event.Article.Diff.Added != 2 {
+			t.Errorf("Unexpected payload %v", string(body))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Webhook was not delivered")
+	}
+
+	deliveries := ws.Deliveries()
+	if len(deliveries) != 1 {
+		t.Fatalf("Delivery log has %v entries, should have 1", len(deliveries))
+	}
+}
@@ -1,9 +1,10 @@
 package routes
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"net/mail"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -13,19 +14,63 @@ import (
 	"alexandria.app/view"
 )
 
+type adminViewData struct {
+	Users      []*models.User
+	Webhooks   []*models.Webhook
+	Deliveries []models.WebhookDelivery
+	Events     []string
+}
+
+type checkViewData struct {
+	Problems []*models.Problem
+	Fixable  bool
+}
+
 // AdminRoutes sets up all HTTP routes for admin tasks in the wiki.
-func AdminRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage, sessionStorage *models.SessionStorage) {
+func AdminRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage, sessionStorage *models.SessionStorage, webhookStorage *models.WebhookStorage) {
 	r.HandleFunc("/admin", func(w http.ResponseWriter, r *http.Request) {
 		user := models.GetRequestUser(r)
 
+		data := &adminViewData{
+			Users:      userStorage.GetUsers(),
+			Webhooks:   webhookStorage.GetWebhooks(),
+			Deliveries: webhookStorage.Deliveries(),
+			Events:     models.Events,
+		}
+
 		v := view.New("layout", "admin", config)
-		if err := v.Render(w, user, userStorage.GetUsers()); err != nil {
-			log.Print(err)
+		if err := v.Render(w, user, data); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to render admin view", "error", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	}).Methods(http.MethodGet)
 
+	r.HandleFunc("/admin/check", func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		// Problems are only fixed when the form is submitted.
+		problems, err := models.CheckConsistency(config, userStorage, r.Method == http.MethodPost)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to check the content directory", "error", err)
+			view.RenderErrorView("Failed to check the content directory.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		data := &checkViewData{Problems: problems}
+		for _, p := range problems {
+			if p.Fixable && !p.Fixed {
+				data.Fixable = true
+			}
+		}
+
+		v := view.New("layout", "check", config)
+		if err := v.Render(w, user, data); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to render check view", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}).Methods(http.MethodGet, http.MethodPost)
+
 	r.HandleFunc("/admin/create_user", func(w http.ResponseWriter, r *http.Request) {
 		session := models.GetRequestSession(r)
 
@@ -54,7 +99,7 @@ func AdminRoutes(r *mux.Router, config *models.Config, userStorage models.UserSt
 
 		user, err := models.NewUser(email, displayName, password, admin)
 		if err != nil {
-			log.Fatal(err)
+			slog.ErrorContext(r.Context(), "Failed to create new user", "error", err)
 			view.RenderErrorView("Failed to create new user.", http.StatusInternalServerError, config, session.User, w)
 			return
 		}
@@ -68,12 +113,14 @@ func AdminRoutes(r *mux.Router, config *models.Config, userStorage models.UserSt
 
 		err = userStorage.Save()
 		if err != nil {
-			log.Fatal(err)
+			slog.ErrorContext(r.Context(), "Failed to save user database", "error", err)
 			view.RenderErrorView("Failed to save user database.", http.StatusInternalServerError, config, session.User, w)
 			return
 		}
 
-		http.Redirect(w, r, "/admin", http.StatusFound)
+		webhookStorage.Dispatch(models.NewUserEvent(models.EventUserAdded, session.User, user))
+
+		http.Redirect(w, r, config.URLPath("/admin"), http.StatusFound)
 	}).Methods(http.MethodPost)
 
 	r.HandleFunc("/admin/delete_user", func(w http.ResponseWriter, r *http.Request) {
@@ -86,21 +133,95 @@ func AdminRoutes(r *mux.Router, config *models.Config, userStorage models.UserSt
 		}
 		id := uint32(idt)
 
+		deletedUser := userStorage.GetUserByID(id)
+		if deletedUser == nil {
+			view.RenderErrorView("Unknown user id.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
 		userStorage.DeleteUser(id)
 
 		if err = userStorage.Save(); err != nil {
-			log.Print(err)
+			slog.ErrorContext(r.Context(), "Failed to save user database", "error", err)
 			view.RenderErrorView("Failed to save user database.", http.StatusInternalServerError, config, user, w)
 			return
 		}
 
 		sessionStorage.RemoveSessionsForUser(id)
 
+		webhookStorage.Dispatch(models.NewUserEvent(models.EventUserRemoved, user, deletedUser))
+
 		if user.ID == id {
-			http.SetCookie(w, models.RemoveSessionCookie(false))
-			http.Redirect(w, r, "/", http.StatusFound)
+			http.SetCookie(w, models.RemoveSessionCookie(config.IsHTTPS(r), config.BasePath))
+			http.Redirect(w, r, config.URLPath("/"), http.StatusFound)
 		} else {
-			http.Redirect(w, r, "/admin", http.StatusFound)
+			http.Redirect(w, r, config.URLPath("/admin"), http.StatusFound)
+		}
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/admin/create_webhook", func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+
+		if err := r.ParseForm(); err != nil {
+			view.RenderErrorView("Invalid form data.", http.StatusBadRequest, config, user, w)
+			return
 		}
+
+		hookURL := strings.TrimSpace(r.FormValue("url"))
+		secret := r.FormValue("secret")
+
+		parsedURL, err := url.Parse(hookURL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || len(parsedURL.Host) == 0 {
+			view.RenderErrorView("Invalid webhook URL.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		if len(secret) == 0 {
+			view.RenderErrorView("Webhook secret empty.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		events := []string{}
+		for _, e := range r.Form["events"] {
+			for _, known := range models.Events {
+				if e == known {
+					events = append(events, e)
+				}
+			}
+		}
+
+		webhookStorage.AddWebhook(&models.Webhook{
+			URL:    parsedURL.String(),
+			Events: events,
+			Secret: secret,
+		})
+
+		if err := webhookStorage.Save(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save webhook database", "error", err)
+			view.RenderErrorView("Failed to save webhook database.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		http.Redirect(w, r, config.URLPath("/admin"), http.StatusFound)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/admin/delete_webhook", func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+
+		idt, err := strconv.ParseUint(r.FormValue("id"), 10, 32)
+		if err != nil {
+			view.RenderErrorView("Invalid webhook id.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		webhookStorage.DeleteWebhook(uint32(idt))
+
+		if err = webhookStorage.Save(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save webhook database", "error", err)
+			view.RenderErrorView("Failed to save webhook database.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		http.Redirect(w, r, config.URLPath("/admin"), http.StatusFound)
 	}).Methods(http.MethodPost)
 }
@@ -1,31 +1,226 @@
 package routes
 
 import (
+	"context"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	"alexandria.app/diff"
 	"alexandria.app/models"
 	"alexandria.app/view"
 
 	"github.com/gorilla/mux"
 )
 
+type editorViewData struct {
+	Formats   []*models.Format
+	Format    string
+	Title     string
+	Content   string
+	Templates []string
+	// Template is the name of the template the content was created from, if any.
+	Template string
+}
+
+type categoryViewData struct {
+	*models.Category
+	Template  string
+	Templates []string
+}
+
+type articleViewData struct {
+	Path        string
+	Body        template.HTML
+	TOC         *models.TableOfContents
+	Fields      []articleField
+	Attachments []*models.Attachment
+}
+
+type articleField struct {
+	Name  string
+	Value string
+}
+
+// articleFields returns the article's custom fields, sorted by name.
+func articleFields(article *models.Article) []articleField {
+	fields := make([]articleField, 0, len(article.Meta.Fields))
+	for name, value := range article.Meta.Fields {
+		fields = append(fields, articleField{Name: name, Value: models.FormatFieldValue(value)})
+	}
+
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+
+	return fields
+}
+
+// validArticlePath checks that the path only consists of characters which are allowed in article paths.
+// As dots aren't allowed the path can't escape the content directory.
+func validArticlePath(path string) bool {
+	return models.ValidArticleName(path)
+}
+
+// canEditArticle checks if the user may change the article at path. Only admins can change templates.
+func canEditArticle(config *models.Config, user *models.User, path string) bool {
+	return user.Admin || !models.IsArticleTemplate(config, path)
+}
+
+// templateValues returns the values filled in for a template's placeholders, title is the article's path.
+func templateValues(user *models.User, title string) models.TemplateValues {
+	values := models.TemplateValues{Author: user.DisplayName, Date: time.Now()}
+	if len(title) != 0 {
+		values.Title = path.Base(title)
+	}
+
+	return values
+}
+
+// formatFromRequest returns the format selected in the editor, or nil if the format doesn't exist.
+func formatFromRequest(r *http.Request) *models.Format {
+	ext := r.FormValue("format")
+	if len(ext) == 0 {
+		ext = models.DefaultFormat
+	}
+
+	return models.FormatByExtension(ext)
+}
+
+// recordChange stores a new revision of the article and notifies all webhooks about the change.
+// Failing to store the revision is logged but doesn't fail the request as the article itself has already been changed.
+func recordChange(ctx context.Context, revisionStorage *models.RevisionStorage, webhookStorage *models.WebhookStorage, user *models.User, eventType, path, oldPath, title, format, summary string, oldContent, newContent []byte) {
+	changes := diff.Summarize(diff.Lines(string(oldContent), string(newContent)))
+
+	rev := &models.Revision{
+		Event:      eventType,
+		Path:       path,
+		OldPath:    oldPath,
+		AuthorID:   user.ID,
+		AuthorName: user.DisplayName,
+		Summary:    summary,
+		Diff:       changes,
+		Format:     format,
+	}
+
+	if err := revisionStorage.AddRevision(rev, newContent); err != nil {
+		slog.ErrorContext(ctx, "Failed to record revision", "error", err)
+	}
+
+	webhookStorage.Dispatch(models.NewArticleEvent(eventType, user, path, oldPath, title, changes))
+}
+
+// saveArticle writes the source to the article at path in the format with the extension ext,
+// creating the article if it doesn't exist yet, and records the change.
+// source is the article as written in the editor, its front matter becomes the article's fields.
+func saveArticle(ctx context.Context, config *models.Config, revisionStorage *models.RevisionStorage, webhookStorage *models.WebhookStorage, user *models.User, path, source, summary, ext string) error {
+	dir := filepath.Join(config.ContentPath, filepath.Dir(path))
+	fileName := filepath.Base(path)
+
+	fields, content, err := models.SplitFrontMatter([]byte(source))
+	if err != nil {
+		return err
+	}
+
+	article := models.NewArticle(fileName, string(content), dir, ext)
+	article.Meta.Fields = fields
+
+	eventType := models.EventArticleCreated
+	var oldSource []byte
+	oldPath, err := models.FindArticle(filepath.Join(config.ContentPath, path))
+	if err == nil {
+		if oldArticle, err := models.LoadArticle(oldPath); err == nil {
+			eventType = models.EventArticleEdited
+			oldSource = oldArticle.Source()
+		}
+	}
+
+	if err := article.Write(); err != nil {
+		return err
+	}
+
+	// The article's format changed, so the old file has to go.
+	if len(oldPath) != 0 && oldPath != article.Path {
+		if err := models.RemoveArticleFile(oldPath); err != nil {
+			return err
+		}
+	}
+
+	recordChange(ctx, revisionStorage, webhookStorage, user, eventType, path, "", article.Meta.Title, ext, summary, oldSource, article.Source())
+
+	return nil
+}
+
 // ArticleRoutes sets up all HTTP routes for creating/viewing/editing routes and categories.
-func ArticleRoutes(r *mux.Router, config *models.Config) {
+func ArticleRoutes(r *mux.Router, config *models.Config, webhookStorage *models.WebhookStorage, revisionStorage *models.RevisionStorage) {
+	renderCache := models.NewRenderCache(config)
+
 	r.HandleFunc("/articles/", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, "/", http.StatusFound)
+		http.Redirect(w, r, config.URLPath("/"), http.StatusFound)
 	}).Methods(http.MethodGet)
 
 	r.HandleFunc("/articles/new", func(w http.ResponseWriter, r *http.Request) {
 		user := models.GetRequestUser(r)
+		title := strings.TrimSpace(r.FormValue("title"))
+		category := strings.Trim(r.FormValue("category"), "/ ")
+		templateName := r.FormValue("template")
+
+		// A title ending with a slash only names the category, e.g. when switching templates.
+		if strings.HasSuffix(title, "/") {
+			category = strings.Trim(title, "/ ")
+			title = ""
+		}
+		title = strings.Trim(title, "/ ")
+
+		if (len(title) != 0 && !validArticlePath(title)) || (len(category) != 0 && !validArticlePath(category)) {
+			view.RenderErrorView("Invalid article path.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		templates, err := models.ListArticleTemplates(config)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to list article templates", "error", err)
+		}
+
+		data := &editorViewData{
+			Formats:   models.Formats(),
+			Format:    models.DefaultFormat,
+			Title:     title,
+			Templates: templates,
+		}
+
+		if len(category) != 0 {
+			if len(title) == 0 {
+				data.Title = category + "/"
+			}
+
+			if len(templateName) == 0 {
+				if templateName, err = models.DefaultTemplate(config, category); err != nil {
+					slog.ErrorContext(r.Context(), "Failed to read category settings", "error", err)
+				}
+			}
+		}
+
+		if len(templateName) != 0 {
+			tmpl, err := models.LoadArticleTemplate(config, templateName)
+			if err != nil {
+				view.RenderErrorView("Template not found.", http.StatusNotFound, config, user, w)
+				return
+			}
+
+			// The title may not be known yet, its placeholder is then filled in when the article is saved.
+			data.Template = templateName
+			data.Format = tmpl.Format().Extension
+			data.Content = string(models.ExpandTemplate(tmpl.Source(), templateValues(user, title)))
+		}
 
 		v := view.New("layout", "editor", config)
-		if err := v.Render(w, user, nil); err != nil {
-			log.Print(err)
+		if err := v.Render(w, user, data); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to render editor view", "error", err)
 			view.RenderErrorView("Failed to render editor view.", http.StatusInternalServerError, config, user, w)
 			return
 		}
@@ -33,26 +228,222 @@ func ArticleRoutes(r *mux.Router, config *models.Config) {
 
 	r.HandleFunc("/articles/save", func(w http.ResponseWriter, r *http.Request) {
 		user := models.GetRequestUser(r)
-		title := strings.TrimSpace(r.FormValue("title"))
+		title := strings.Trim(r.FormValue("title"), "/ ")
 		content := strings.TrimSpace(r.FormValue("content"))
+		summary := strings.TrimSpace(r.FormValue("summary"))
+		format := formatFromRequest(r)
+
+		if !validArticlePath(title) {
+			view.RenderErrorView("Invalid article title.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		if format == nil {
+			view.RenderErrorView("Unknown article format.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		if !canEditArticle(config, user, title) {
+			view.RenderErrorView("Only admins can change article templates.", http.StatusForbidden, config, user, w)
+			return
+		}
 
 		// For some reason when the browser POSTs data from the <textarea> it inserts `\r` before every
 		// `\n` character. Because the markdown spec defines newlines as `\n` only, we need
 		// to remove the offending `\r`s.
 		content = strings.Replace(content, "\r", "", -1)
 
-		dir := filepath.Join(config.ContentPath, filepath.Dir(title))
-		fileName := filepath.Base(title)
+		if len(r.FormValue("template")) != 0 {
+			content = string(models.ExpandTemplate([]byte(content), templateValues(user, title)))
+		}
+
+		if _, _, err := models.SplitFrontMatter([]byte(content)); err != nil {
+			view.RenderErrorView("Invalid front matter: "+err.Error(), http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		if err := saveArticle(r.Context(), config, revisionStorage, webhookStorage, user, title, content, summary, format.Extension); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to write article file", "error", err)
+			view.RenderErrorView("Failed to write article file.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		http.Redirect(w, r, config.URLPath("/articles/"+title), http.StatusFound)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/articles/preview", func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		title := strings.Trim(r.FormValue("title"), "/ ")
+		content := strings.Replace(strings.TrimSpace(r.FormValue("content")), "\r", "", -1)
+		format := formatFromRequest(r)
+
+		if !validArticlePath(title) {
+			view.RenderErrorView("Invalid article title.", http.StatusBadRequest, config, user, w)
+			return
+		}
 
-		article := models.NewArticle(fileName, content, dir)
+		if format == nil {
+			view.RenderErrorView("Unknown article format.", http.StatusBadRequest, config, user, w)
+			return
+		}
 
-		err := article.Write()
+		fields, body, err := models.SplitFrontMatter([]byte(content))
 		if err != nil {
-			view.RenderErrorView("Failed to write article file.", http.StatusInternalServerError, config, user, w)
+			view.RenderErrorView("Invalid front matter: "+err.Error(), http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		// The article is never written, it only exists so that the preview is rendered exactly like the saved article.
+		article := models.NewArticle(filepath.Base(title), string(body), filepath.Join(config.ContentPath, filepath.Dir(title)), format.Extension)
+		article.Meta.Fields = fields
+
+		html, err := article.ContentHTML(config)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to render content as HTML", "error", err)
+			view.RenderErrorView("Failed to render content as HTML.", http.StatusInternalServerError, config, user, w)
 			return
 		}
 
-		http.Redirect(w, r, "/articles/"+title, http.StatusFound)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(html)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/categories/template", func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		category := strings.Trim(r.FormValue("category"), "/ ")
+		templateName := r.FormValue("template")
+
+		if !user.Admin {
+			view.RenderErrorView("", http.StatusForbidden, config, user, w)
+			return
+		}
+
+		if !validArticlePath(category) {
+			view.RenderErrorView("Invalid category.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		if stat, err := os.Stat(filepath.Join(config.ContentPath, category)); err != nil || !stat.IsDir() {
+			view.RenderErrorView("", http.StatusNotFound, config, user, w)
+			return
+		}
+
+		if len(templateName) != 0 {
+			if _, err := models.LoadArticleTemplate(config, templateName); err != nil {
+				view.RenderErrorView("Template not found.", http.StatusBadRequest, config, user, w)
+				return
+			}
+		}
+
+		settings, err := models.LoadCategorySettings(config, category)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to read category settings", "error", err)
+			view.RenderErrorView("Failed to read category settings.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		settings.Template = templateName
+
+		if err := models.SaveCategorySettings(config, category, settings); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to write category settings", "error", err)
+			view.RenderErrorView("Failed to write category settings.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		http.Redirect(w, r, config.URLPath("/articles/"+category), http.StatusFound)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/articles/move", func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		path := strings.Trim(r.FormValue("path"), "/ ")
+		newPath := strings.Trim(r.FormValue("new_path"), "/ ")
+
+		if !validArticlePath(path) || !validArticlePath(newPath) {
+			view.RenderErrorView("Invalid article path.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		if !canEditArticle(config, user, path) || !canEditArticle(config, user, newPath) {
+			view.RenderErrorView("Only admins can change article templates.", http.StatusForbidden, config, user, w)
+			return
+		}
+
+		realPath, err := models.FindArticle(filepath.Join(config.ContentPath, path))
+		if err != nil {
+			view.RenderErrorView("", http.StatusNotFound, config, user, w)
+			return
+		}
+
+		article, err := models.LoadArticle(realPath)
+		if err != nil {
+			view.RenderErrorView("", http.StatusNotFound, config, user, w)
+			return
+		}
+
+		newRealPath := filepath.Join(config.ContentPath, newPath) + filepath.Ext(realPath)
+
+		if _, err := models.FindArticle(filepath.Join(config.ContentPath, newPath)); err == nil {
+			view.RenderErrorView("An article with that name already exists.", http.StatusConflict, config, user, w)
+			return
+		}
+
+		if err := models.MoveArticle(realPath, newRealPath); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to move article", "error", err)
+			view.RenderErrorView("Failed to move article.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		recordChange(r.Context(), revisionStorage, webhookStorage, user, models.EventArticleMoved, newPath, path, article.Meta.Title, article.Format().Extension, "", article.Source(), article.Source())
+
+		http.Redirect(w, r, config.URLPath("/articles/"+newPath), http.StatusFound)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/articles/delete", func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		articlePath := strings.Trim(r.FormValue("path"), "/ ")
+
+		if !validArticlePath(articlePath) {
+			view.RenderErrorView("Invalid article path.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		if !canEditArticle(config, user, articlePath) {
+			view.RenderErrorView("Only admins can change article templates.", http.StatusForbidden, config, user, w)
+			return
+		}
+
+		realPath, err := models.FindArticle(filepath.Join(config.ContentPath, articlePath))
+		if err != nil {
+			view.RenderErrorView("", http.StatusNotFound, config, user, w)
+			return
+		}
+
+		article, err := models.LoadArticle(realPath)
+		if err != nil {
+			view.RenderErrorView("", http.StatusNotFound, config, user, w)
+			return
+		}
+
+		if err := models.RemoveArticleFile(realPath); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete article file", "error", err)
+			view.RenderErrorView("Failed to delete article file.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		if err := os.RemoveAll(models.AttachmentDirectory(realPath)); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete article attachments", "error", err)
+		}
+
+		recordChange(r.Context(), revisionStorage, webhookStorage, user, models.EventArticleDeleted, articlePath, "", article.Meta.Title, article.Format().Extension, "", article.Source(), nil)
+
+		// Top-level articles don't have a category to return to.
+		category := path.Dir(articlePath)
+		if category == "." {
+			http.Redirect(w, r, config.URLPath("/"), http.StatusFound)
+			return
+		}
+
+		http.Redirect(w, r, config.URLPath("/articles/"+category), http.StatusFound)
 	}).Methods(http.MethodPost)
 
 	r.HandleFunc(`/articles/{path:[\w\d_ /-]+}`, func(w http.ResponseWriter, r *http.Request) {
@@ -76,29 +467,68 @@ func ArticleRoutes(r *mux.Router, config *models.Config) {
 				return
 			}
 
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			data := &categoryViewData{Category: category}
+
+			settings, err := models.LoadCategorySettings(config, path)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to read category settings", "error", err)
+			} else {
+				data.Template = settings.Template
+			}
+
+			if data.Templates, err = models.ListArticleTemplates(config); err != nil {
+				slog.ErrorContext(r.Context(), "Failed to list article templates", "error", err)
+			}
+
+			// The directory's modification time doesn't change when an article in it is edited, e.g. its title,
+			// so only the ETag decides whether the page changed.
 			v := view.New("layout", "category", config)
-			if err := v.Render(w, user, category); err != nil {
-				log.Print(err)
+			if err := v.Serve(w, r, user, data, time.Time{}); err != nil {
+				slog.ErrorContext(r.Context(), "Failed to render category view", "error", err)
 				view.RenderErrorView("Failed to render category view.", http.StatusInternalServerError, config, user, w)
 				return
 			}
 		} else {
-			article, err := models.LoadArticle(realPath + ".md")
+			articlePath, err := models.FindArticle(realPath)
 			if err != nil {
 				view.RenderErrorView("", http.StatusNotFound, config, user, w)
 				return
 			}
 
-			body, err := article.ContentHTML()
-			if err != nil {
+			article, err := renderCache.Load(articlePath)
+			if os.IsNotExist(err) {
+				view.RenderErrorView("", http.StatusNotFound, config, user, w)
+				return
+			} else if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to render content as HTML", "error", err)
 				view.RenderErrorView("Failed to render content as HTML.", http.StatusInternalServerError, config, user, w)
 				return
 			}
 
+			attachments, err := models.ListAttachments(models.AttachmentDirectory(article.Path))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to list attachments", "error", err)
+			}
+
+			// Articles with queries have no modification time, the ETag decides whether they changed.
+			modTime := article.ModTime
+			for _, attachment := range attachments {
+				if !modTime.IsZero() && attachment.ModTime.After(modTime) {
+					modTime = attachment.ModTime
+				}
+			}
+
+			data := &articleViewData{
+				Path:        path,
+				Body:        template.HTML(article.HTML),
+				TOC:         article.TOC,
+				Fields:      articleFields(article.Article),
+				Attachments: attachments,
+			}
+
 			v := view.New("layout", "article", config)
-			if err := v.Render(w, user, template.HTML(body)); err != nil {
-				log.Print(err)
+			if err := v.Serve(w, r, user, data, modTime); err != nil {
+				slog.ErrorContext(r.Context(), "Failed to render article view", "error", err)
 				view.RenderErrorView("Failed to render article view.", http.StatusInternalServerError, config, user, w)
 				return
 			}
@@ -1,13 +1,36 @@
 package routes
 
 import (
+	"bytes"
+	"log/slog"
 	"net/http"
+	"os"
+	"time"
 
+	"alexandria.app/assets"
 	"alexandria.app/models"
 	"github.com/gorilla/mux"
 )
 
 // AssetRoutes sets up all routes for static assets such as CSS and JavaScript files.
 func AssetRoutes(r *mux.Router, config *models.Config) {
-	r.PathPrefix(`/assets/{f:[\d\w]+\.[js|css]}`).Handler(http.StripPrefix("/assets/", http.FileServer(http.Dir(config.AssetPath))))
+	var highlightCSS bytes.Buffer
+	if err := models.WriteHighlightCSS(&highlightCSS, config.HighlightStyle); err != nil {
+		slog.Error("Failed to generate highlight stylesheet", "error", err)
+	}
+	modTime := time.Now()
+
+	// The stylesheet for code blocks is generated from the configured style, so it can't be a static file.
+	r.HandleFunc("/assets/highlight.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		http.ServeContent(w, r, "highlight.css", modTime, bytes.NewReader(highlightCSS.Bytes()))
+	})
+
+	// The embedded assets are used unless another directory is configured, e.g. for theming.
+	files := assets.Public()
+	if len(config.AssetPath) != 0 {
+		files = os.DirFS(config.AssetPath)
+	}
+
+	r.PathPrefix(`/assets/{f:[\d\w]+\.[js|css]}`).Handler(http.StripPrefix(config.URLPath("/assets/"), http.FileServer(http.FS(files))))
 }
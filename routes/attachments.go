@@ -0,0 +1,150 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"alexandria.app/models"
+	"alexandria.app/view"
+)
+
+// Multipart overhead (boundaries and headers) allowed on top of the attachment size limit.
+const uploadOverhead = 64 * 1024
+
+type uploadResponse struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Link string `json:"link"`
+}
+
+// attachmentLink returns the markup which references the attachment from an article in the format.
+func attachmentLink(format *models.Format, a *models.Attachment) string {
+	if a.IsImage() {
+		return format.Link(strings.TrimSuffix(a.Name, filepath.Ext(a.Name)), models.AttachmentLinkPrefix+a.Name, true)
+	}
+
+	return format.Link(a.Name, models.AttachmentLinkPrefix+a.Name, false)
+}
+
+// inlineContentType checks if browsers can safely display files of the content type instead of downloading them.
+func inlineContentType(contentType string) bool {
+	if contentType == "image/svg+xml" {
+		return false
+	}
+
+	return strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "text/plain") || contentType == "application/pdf"
+}
+
+// AttachmentRoutes sets up the HTTP routes for uploading and downloading article attachments.
+func AttachmentRoutes(r *mux.Router, config *models.Config) {
+	r.HandleFunc(`/attachments/{path:[\w\d_ /-]+}`, func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		path := strings.Trim(mux.Vars(r)["path"], "/ ")
+
+		if !validArticlePath(path) {
+			view.RenderErrorView("Invalid article path.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		if !canEditArticle(config, user, path) {
+			view.RenderErrorView("Only admins can change article templates.", http.StatusForbidden, config, user, w)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, config.AttachmentMaxSize+uploadOverhead)
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			view.RenderErrorView("Missing file or file too large.", http.StatusBadRequest, config, user, w)
+			return
+		}
+		defer file.Close()
+
+		dir := models.AttachmentDirectory(filepath.Join(config.ContentPath, path))
+		name := filepath.Base(header.Filename)
+
+		attachment, err := models.SaveAttachment(dir, name, file, config)
+		switch err {
+		case nil:
+		case models.ErrInvalidAttachmentName, models.ErrAttachmentTypeNotAllowed:
+			view.RenderErrorView(err.Error()+".", http.StatusBadRequest, config, user, w)
+			return
+		case models.ErrAttachmentTooLarge:
+			view.RenderErrorView(err.Error()+".", http.StatusRequestEntityTooLarge, config, user, w)
+			return
+		default:
+			slog.ErrorContext(r.Context(), "Failed to save attachment", "error", err)
+			view.RenderErrorView("Failed to save attachment.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		if !strings.Contains(r.Header.Get("Accept"), "application/json") {
+			http.Redirect(w, r, config.URLPath("/articles/"+path), http.StatusFound)
+			return
+		}
+
+		format := formatFromRequest(r)
+		if format == nil {
+			format = models.FormatByExtension(models.DefaultFormat)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&uploadResponse{
+			Name: attachment.Name,
+			URL:  models.AttachmentURL(config, path, attachment.Name),
+			Link: attachmentLink(format, attachment),
+		})
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc(`/attachments/{path:[\w\d_ /-]+}/{name:[\w\d_ -]+\.[\w\d]+}`, func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		vars := mux.Vars(r)
+
+		dir := models.AttachmentDirectory(filepath.Join(config.ContentPath, vars["path"]))
+
+		attachment, err := models.LoadAttachment(dir, vars["name"])
+		if err != nil {
+			view.RenderErrorView("", http.StatusNotFound, config, user, w)
+			return
+		}
+
+		if width, err := strconv.Atoi(r.FormValue("w")); err == nil && attachment.CanResize() {
+			if width = models.ThumbnailWidth(width); width != 0 {
+				thumbnail, err := models.Thumbnail(attachment, vars["path"], width, config.CachePath)
+				if err == nil {
+					attachment = thumbnail
+				} else if !errors.Is(err, models.ErrImageTooLarge) {
+					// The original can still be served, it's just bigger than requested.
+					slog.ErrorContext(r.Context(), "Failed to create thumbnail", "error", err)
+				}
+			}
+		}
+
+		file, err := os.Open(attachment.Path)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to read attachment", "error", err)
+			view.RenderErrorView("Failed to read attachment.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+		defer file.Close()
+
+		contentType := attachment.ContentType()
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if !inlineContentType(contentType) {
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, attachment.Name))
+		}
+
+		http.ServeContent(w, r, attachment.Name, attachment.ModTime, file)
+	}).Methods(http.MethodGet)
+}
@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/gorilla/mux"
+
+	"alexandria.app/models"
+	"alexandria.app/view"
+)
+
+type healthCheck struct {
+	Status string `json:"status"`
+}
+
+type healthViewData struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks,omitempty"`
+}
+
+// checkContentPath makes sure articles can be listed and written. Nothing is written, as changing the content
+// directory would invalidate the cached results of queries. Before the first article is saved the content
+// directory doesn't exist yet, then the data directory it will be created in has to be writable.
+func checkContentPath(config *models.Config) error {
+	_, err := os.ReadDir(config.ContentPath)
+	if os.IsNotExist(err) {
+		return checkWritable(config.DataPath)
+	} else if err != nil {
+		return err
+	}
+
+	return checkWritable(config.ContentPath)
+}
+
+// checkUserStorage makes sure the user database can still be read. Before the setup there are no users and
+// nothing has been saved yet.
+func checkUserStorage(config *models.Config, userStorage models.UserStorage) error {
+	if userStorage.IsEmpty() {
+		return nil
+	}
+
+	file, err := os.Open(config.UserStoragePath)
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, data *healthViewData) {
+	statusCode := http.StatusOK
+	if data.Status != "ok" {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write health status", "error", err)
+	}
+}
+
+// HealthRoutes sets up the routes used by orchestrators to check whether the wiki is alive and ready to serve
+// requests. They don't require a login, so they have to be registered outside of the routes which do.
+func HealthRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage) {
+	checks := map[string]func() error{
+		"content": func() error {
+			return checkContentPath(config)
+		},
+		"users": func() error {
+			return checkUserStorage(config, userStorage)
+		},
+		"templates": func() error {
+			return view.TemplatesLoaded("layout", config)
+		},
+	}
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// The process is alive as long as it handles requests.
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, r, &healthViewData{Status: "ok"})
+	}).Methods(http.MethodGet, http.MethodHead)
+
+	r.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		data := &healthViewData{Status: "ok", Checks: map[string]healthCheck{}}
+
+		// The errors can contain paths and other details, which are only logged as the checks don't require a login.
+		for _, name := range names {
+			if err := checks[name](); err != nil {
+				slog.WarnContext(r.Context(), "Readiness check failed", "check", name, "error", err)
+				data.Status = "unavailable"
+				data.Checks[name] = healthCheck{Status: "failed"}
+				continue
+			}
+
+			data.Checks[name] = healthCheck{Status: "ok"}
+		}
+
+		writeHealth(w, r, data)
+	}).Methods(http.MethodGet, http.MethodHead)
+}
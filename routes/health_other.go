@@ -0,0 +1,11 @@
+//go:build !unix
+
+package routes
+
+import "os"
+
+// checkWritable only checks that the directory exists, as there is no access(2) on this system.
+func checkWritable(dir string) error {
+	_, err := os.Stat(dir)
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build unix
+
+package routes
+
+import "golang.org/x/sys/unix"
+
+// checkWritable checks that files can be created in the directory without creating one.
+func checkWritable(dir string) error {
+	return unix.Access(dir, unix.W_OK)
+}
@@ -1,7 +1,7 @@
 package routes
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -14,27 +14,27 @@ import (
 func IndexRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage) {
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if userStorage.IsEmpty() {
-			http.Redirect(w, r, "/setup", http.StatusFound)
+			http.Redirect(w, r, config.URLPath("/setup"), http.StatusFound)
 			return
 		}
 
 		user := models.GetRequestUser(r)
 		if user == nil {
-			http.Redirect(w, r, "/login", http.StatusFound)
+			http.Redirect(w, r, config.URLPath("/login"), http.StatusFound)
 			return
 		}
 
 		var category *models.Category
 		if category = models.NewCategory("", config.ContentPath); category != nil {
 			if err := category.ScanEntries(); err != nil {
-				log.Printf("Error while reading root categroy %v", err)
+				slog.ErrorContext(r.Context(), "Failed to read root category", "error", err)
 			}
 		}
 
 		v := view.New("layout", "index", config)
 
 		if err := v.Render(w, user, category); err != nil {
-			log.Print(err)
+			slog.ErrorContext(r.Context(), "Failed to render index layout", "error", err)
 			view.RenderErrorView("Failed to render index layout.", http.StatusInternalServerError, config, user, w)
 			return
 		}
@@ -1,23 +1,29 @@
 package routes
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/gorilla/mux"
 
+	"alexandria.app/metrics"
 	"alexandria.app/models"
 	"alexandria.app/view"
 )
 
+var logins = metrics.NewCounter("alexandria_logins_total", "Number of login attempts by result, either success or failure.", "result")
+
 // LoginRoutes sets up all HTTP routes for user login.
 func LoginRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage, sessionStorage *models.SessionStorage) {
+	logins.Add(0, "success")
+	logins.Add(0, "failure")
+
 	r.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
 		v := view.New("layout", "login", config)
 
 		if err := v.Render(w, nil, nil); err != nil {
-			log.Print(err)
+			slog.ErrorContext(r.Context(), "Failed to render login view", "error", err)
 			view.RenderErrorView("Failed to render login view.", http.StatusInternalServerError, config, nil, w)
 		}
 	}).Methods(http.MethodGet)
@@ -31,7 +37,7 @@ func LoginRoutes(r *mux.Router, config *models.Config, userStorage models.UserSt
 			v := view.New("layout", "login", config)
 
 			if err := v.Render(w, nil, nil); err != nil {
-				log.Print(err)
+				slog.ErrorContext(r.Context(), "Failed to render login view", "error", err)
 				view.RenderErrorView("Failed to render login view.", http.StatusInternalServerError, config, nil, w)
 				return
 			}
@@ -41,23 +47,26 @@ func LoginRoutes(r *mux.Router, config *models.Config, userStorage models.UserSt
 
 		user := userStorage.CheckUserLogin(email, password)
 		if user == nil {
+			logins.Inc("failure")
 			w.WriteHeader(http.StatusUnauthorized)
 			v := view.New("layout", "login", config)
 
 			if err := v.Render(w, nil, nil); err != nil {
-				log.Print(err)
+				slog.ErrorContext(r.Context(), "Failed to render login view", "error", err)
 				view.RenderErrorView("Failed to render login view.", http.StatusInternalServerError, config, nil, w)
 				return
 			}
 			return
 		}
 
+		logins.Inc("success")
+
 		session := models.NewSession(user)
 
 		sessionStorage.AddSession(session)
 
-		http.SetCookie(w, session.Cookie(false))
+		http.SetCookie(w, session.Cookie(config.IsHTTPS(r), config.BasePath))
 
-		http.Redirect(w, r, "/", http.StatusFound)
+		http.Redirect(w, r, config.URLPath("/"), http.StatusFound)
 	}).Methods(http.MethodPost)
 }
@@ -9,11 +9,11 @@ import (
 )
 
 // LogoutRoutes sets up all HTTP routes for user logout.
-func LogoutRoutes(r *mux.Router, sessionStorage *models.SessionStorage) {
+func LogoutRoutes(r *mux.Router, config *models.Config, sessionStorage *models.SessionStorage) {
 	r.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
 		session := models.GetRequestSession(r)
 		sessionStorage.RemoveSession(session)
-		http.SetCookie(w, models.RemoveSessionCookie(false))
-		http.Redirect(w, r, "/", http.StatusFound)
+		http.SetCookie(w, models.RemoveSessionCookie(config.IsHTTPS(r), config.BasePath))
+		http.Redirect(w, r, config.URLPath("/"), http.StatusFound)
 	}).Methods(http.MethodGet)
 }
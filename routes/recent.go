@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"alexandria.app/models"
+	"alexandria.app/view"
+)
+
+const (
+	recentChangesLimit = 100
+	feedLimit          = 50
+)
+
+type recentViewData struct {
+	Revisions  []*models.Revision
+	Users      []*models.User
+	Categories []string
+	Filter     models.RevisionFilter
+	FeedToken  string
+}
+
+// revisionFilterFromRequest reads the category and user filter from the request's query.
+func revisionFilterFromRequest(r *http.Request) models.RevisionFilter {
+	filter := models.RevisionFilter{
+		Category: strings.Trim(r.FormValue("category"), "/ "),
+	}
+
+	if id, err := strconv.ParseUint(r.FormValue("user"), 10, 32); err == nil {
+		filter.AuthorID = uint32(id)
+	}
+
+	return filter
+}
+
+// categories returns all categories which appear in the revisions, sorted by name.
+func categories(revisions []*models.Revision) []string {
+	seen := map[string]bool{}
+	result := []string{}
+
+	for _, rev := range revisions {
+		category := rev.Category()
+		if len(category) != 0 && !seen[category] {
+			seen[category] = true
+			result = append(result, category)
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// RecentRoutes sets up the HTTP routes for the list of recent changes.
+func RecentRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage, revisionStorage *models.RevisionStorage) {
+	r.HandleFunc("/recent", func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+
+		data := &recentViewData{
+			Revisions:  revisionStorage.Recent(revisionFilterFromRequest(r), recentChangesLimit),
+			Users:      userStorage.GetUsers(),
+			Categories: categories(revisionStorage.Recent(models.RevisionFilter{}, 0)),
+			Filter:     revisionFilterFromRequest(r),
+			FeedToken:  user.FeedToken,
+		}
+
+		v := view.New("layout", "recent", config)
+		if err := v.Render(w, user, data); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to render recent changes view", "error", err)
+			view.RenderErrorView("Failed to render recent changes view.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+	}).Methods(http.MethodGet)
+}
+
+// FeedRoutes sets up the HTTP routes for the Atom and RSS feeds of recent changes.
+// As feed readers can't log in, the feeds are authenticated using the user's feed token instead of a session.
+func FeedRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage, revisionStorage *models.RevisionStorage) {
+	feedHandler := func(contentType string, render func(io.Writer, *models.Config, []*models.Revision) error) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if userStorage.GetUserByFeedToken(r.FormValue("token")) == nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			revisions := revisionStorage.Recent(revisionFilterFromRequest(r), feedLimit)
+
+			w.Header().Set("Content-Type", contentType)
+			if err := render(w, config, revisions); err != nil {
+				slog.ErrorContext(r.Context(), "Failed to render feed", "error", err)
+			}
+		}
+	}
+
+	r.HandleFunc("/recent.atom", feedHandler("application/atom+xml; charset=utf-8", view.RenderAtomFeed)).Methods(http.MethodGet)
+	r.HandleFunc("/recent.rss", feedHandler("application/rss+xml; charset=utf-8", view.RenderRSSFeed)).Methods(http.MethodGet)
+}
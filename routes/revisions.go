@@ -0,0 +1,251 @@
+package routes
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"alexandria.app/diff"
+	"alexandria.app/models"
+	"alexandria.app/view"
+)
+
+const unifiedDiffContext = 3
+
+type historyViewData struct {
+	Path      string
+	Revisions []*models.Revision
+}
+
+type renderedBlock struct {
+	Op   diff.Operation
+	HTML template.HTML
+}
+
+type compareViewData struct {
+	Path     string
+	From     *models.Revision
+	To       *models.Revision
+	Inline   bool
+	Rows     []diff.Row
+	Cells    []*diff.Cell
+	Rendered []renderedBlock
+}
+
+// revisionPair looks up the revisions which should be compared, based on the request's query.
+// If only the "to" (or "rev") revision is given it will be compared to its predecessor, in which case from may be nil.
+func revisionPair(r *http.Request, revisionStorage *models.RevisionStorage, path string) (from, to *models.Revision, err error) {
+	toParam := r.FormValue("to")
+	if len(toParam) == 0 {
+		toParam = r.FormValue("rev")
+	}
+
+	toID, err := strconv.ParseInt(toParam, 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if to = revisionStorage.GetRevision(path, toID); to == nil {
+		return nil, nil, fmt.Errorf("Unknown revision %d", toID)
+	}
+
+	fromParam := r.FormValue("from")
+	if len(fromParam) == 0 {
+		return revisionStorage.Previous(to), to, nil
+	}
+
+	fromID, err := strconv.ParseInt(fromParam, 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The old revision may have been saved under a different path if the article has been moved.
+	if from = revisionStorage.GetRevisionByID(fromID); from == nil {
+		return nil, nil, fmt.Errorf("Unknown revision %d", fromID)
+	}
+
+	return from, to, nil
+}
+
+// revisionContents reads the contents of both revisions. A nil revision has empty content.
+func revisionContents(revisionStorage *models.RevisionStorage, from, to *models.Revision) (string, string, error) {
+	var oldContent, newContent []byte
+	var err error
+
+	if from != nil {
+		if oldContent, err = revisionStorage.Content(from); err != nil {
+			return "", "", err
+		}
+	}
+
+	if newContent, err = revisionStorage.Content(to); err != nil {
+		return "", "", err
+	}
+
+	return string(oldContent), string(newContent), nil
+}
+
+func revisionName(rev *models.Revision) string {
+	if rev == nil {
+		return "/dev/null"
+	}
+
+	return fmt.Sprintf("%s@%d", rev.Path, rev.ID)
+}
+
+// RevisionRoutes sets up all HTTP routes for inspecting, comparing and reverting the revisions of an article.
+func RevisionRoutes(r *mux.Router, config *models.Config, webhookStorage *models.WebhookStorage, revisionStorage *models.RevisionStorage) {
+	r.HandleFunc(`/history/{path:[\w\d_ /-]+}`, func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		path := mux.Vars(r)["path"]
+
+		revisions := revisionStorage.ForArticle(path)
+		if len(revisions) == 0 {
+			view.RenderErrorView("", http.StatusNotFound, config, user, w)
+			return
+		}
+
+		v := view.New("layout", "history", config)
+		if err := v.Render(w, user, &historyViewData{Path: path, Revisions: revisions}); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to render history view", "error", err)
+			view.RenderErrorView("Failed to render history view.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc(`/compare/{path:[\w\d_ /-]+}`, func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		path := mux.Vars(r)["path"]
+
+		from, to, err := revisionPair(r, revisionStorage, path)
+		if err != nil {
+			view.RenderErrorView("Invalid revision.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		oldContent, newContent, err := revisionContents(revisionStorage, from, to)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to read revision", "error", err)
+			view.RenderErrorView("Failed to read revision.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		rows := diff.Rows(diff.Lines(oldContent, newContent))
+
+		data := &compareViewData{
+			Path:   path,
+			From:   from,
+			To:     to,
+			Inline: r.FormValue("mode") == "inline",
+			Rows:   rows,
+			Cells:  diff.Inline(rows),
+		}
+
+		if len(r.FormValue("rendered")) != 0 {
+			blocks := diff.Sequences(models.ContentBlocks(oldContent), models.ContentBlocks(newContent))
+			for _, b := range blocks {
+				// Front matter isn't content, it is displayed as it is written.
+				if models.HasFrontMatter([]byte(b.Text)) {
+					data.Rendered = append(data.Rendered, renderedBlock{
+						Op:   b.Op,
+						HTML: template.HTML("<pre>" + template.HTMLEscapeString(b.Text) + "</pre>"),
+					})
+					continue
+				}
+
+				html, _, err := models.RenderArticle([]byte(b.Text), to.ContentFormat(), path, config)
+				if err != nil {
+					slog.ErrorContext(r.Context(), "Failed to render content as HTML", "error", err)
+				}
+				data.Rendered = append(data.Rendered, renderedBlock{
+					Op:   b.Op,
+					HTML: template.HTML(html),
+				})
+			}
+		}
+
+		v := view.New("layout", "compare", config)
+		if err := v.Render(w, user, data); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to render compare view", "error", err)
+			view.RenderErrorView("Failed to render compare view.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc(`/diff/{path:[\w\d_ /-]+}`, func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		path := mux.Vars(r)["path"]
+
+		from, to, err := revisionPair(r, revisionStorage, path)
+		if err != nil {
+			view.RenderErrorView("Invalid revision.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		oldContent, newContent, err := revisionContents(revisionStorage, from, to)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to read revision", "error", err)
+			view.RenderErrorView("Failed to read revision.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		unified := diff.Unified(diff.Lines(oldContent, newContent), revisionName(from), revisionName(to), unifiedDiffContext)
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(r.FormValue("download")) != 0 {
+			fileName := strings.Replace(path, "/", "_", -1) + ".diff"
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+		}
+
+		w.Write([]byte(unified))
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc(`/revert/{path:[\w\d_ /-]+}`, func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+		path := mux.Vars(r)["path"]
+
+		id, err := strconv.ParseInt(r.FormValue("rev"), 10, 64)
+		if err != nil {
+			view.RenderErrorView("Invalid revision id.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		rev := revisionStorage.GetRevision(path, id)
+		if rev == nil {
+			view.RenderErrorView("", http.StatusNotFound, config, user, w)
+			return
+		}
+
+		if !canEditArticle(config, user, path) {
+			view.RenderErrorView("Only admins can change article templates.", http.StatusForbidden, config, user, w)
+			return
+		}
+
+		if rev.Event == models.EventArticleDeleted {
+			view.RenderErrorView("Can't revert to a deleted revision.", http.StatusBadRequest, config, user, w)
+			return
+		}
+
+		content, err := revisionStorage.Content(rev)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to read revision", "error", err)
+			view.RenderErrorView("Failed to read revision.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		summary := fmt.Sprintf("Revert to revision from %s by %s", rev.Time().Format("2006-01-02 15:04"), rev.AuthorName)
+
+		if err := saveArticle(r.Context(), config, revisionStorage, webhookStorage, user, path, string(content), summary, rev.ContentFormat().Extension); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to write article file", "error", err)
+			view.RenderErrorView("Failed to write article file.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		http.Redirect(w, r, config.URLPath("/articles/"+path), http.StatusFound)
+	}).Methods(http.MethodPost)
+}
@@ -1,7 +1,7 @@
 package routes
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"net/mail"
 	"strings"
@@ -12,11 +12,11 @@ import (
 )
 
 // SetupRoutes creates the HTTP routes required for initial setup.
-func SetupRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage, sessionStorage *models.SessionStorage) {
+func SetupRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage, sessionStorage *models.SessionStorage, webhookStorage *models.WebhookStorage) {
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if !userStorage.IsEmpty() {
-				http.Redirect(w, r, "/", http.StatusFound)
+				http.Redirect(w, r, config.URLPath("/"), http.StatusFound)
 			} else {
 				next.ServeHTTP(w, r)
 			}
@@ -26,7 +26,7 @@ func SetupRoutes(r *mux.Router, config *models.Config, userStorage models.UserSt
 	r.HandleFunc("", func(w http.ResponseWriter, r *http.Request) {
 		v := view.New("layout", "setup", config)
 		if err := v.Render(w, nil, nil); err != nil {
-			log.Print(err)
+			slog.ErrorContext(r.Context(), "Failed to render setup view", "error", err)
 			view.RenderErrorView("Failed to render setup view.", http.StatusInternalServerError, config, nil, w)
 			return
 		}
@@ -57,7 +57,7 @@ func SetupRoutes(r *mux.Router, config *models.Config, userStorage models.UserSt
 
 		user, err := models.NewUser(email, displayName, password, true) // First user has to be an admin.
 		if err != nil {
-			log.Fatal(err)
+			slog.ErrorContext(r.Context(), "Failed to create new user", "error", err)
 			view.RenderErrorView("Failed to create new user.", http.StatusInternalServerError, config, nil, w)
 			return
 		}
@@ -71,14 +71,16 @@ func SetupRoutes(r *mux.Router, config *models.Config, userStorage models.UserSt
 
 		err = userStorage.Save()
 		if err != nil {
-			log.Fatal(err)
+			slog.ErrorContext(r.Context(), "Failed to save user database", "error", err)
 			view.RenderErrorView("Failed to save user database.", http.StatusInternalServerError, config, nil, w)
 			return
 		}
 
+		webhookStorage.Dispatch(models.NewUserEvent(models.EventUserAdded, user, user))
+
 		session := models.NewSession(user)
 		sessionStorage.AddSession(session)
-		http.SetCookie(w, session.Cookie(false))
-		http.Redirect(w, r, "/", http.StatusFound)
+		http.SetCookie(w, session.Cookie(config.IsHTTPS(r), config.BasePath))
+		http.Redirect(w, r, config.URLPath("/"), http.StatusFound)
 	}).Methods(http.MethodPost)
 }
@@ -1,7 +1,7 @@
 package routes
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"net/mail"
 	"strconv"
@@ -13,13 +13,13 @@ import (
 )
 
 // UserRoutes sets up all HTTP routes required for user management.
-func UserRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage, sessionStorage *models.SessionStorage) {
+func UserRoutes(r *mux.Router, config *models.Config, userStorage models.UserStorage, sessionStorage *models.SessionStorage, webhookStorage *models.WebhookStorage) {
 	r.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
 		user := models.GetRequestUser(r)
 
 		v := view.New("layout", "user", config)
 		if err := v.Render(w, user, user); err != nil {
-			log.Print(err)
+			slog.ErrorContext(r.Context(), "Failed to render user view", "error", err)
 			view.RenderErrorView("Failed to render user view.", http.StatusInternalServerError, config, user, w)
 			return
 		}
@@ -35,7 +35,7 @@ func UserRoutes(r *mux.Router, config *models.Config, userStorage models.UserSto
 
 		v := view.New("layout", "newuser", config)
 		if err := v.Render(w, user, nil); err != nil {
-			log.Print(err)
+			slog.ErrorContext(r.Context(), "Failed to render new user view", "error", err)
 			view.RenderErrorView("Failed to render new user view.", http.StatusInternalServerError, config, user, w)
 			return
 		}
@@ -61,12 +61,12 @@ func UserRoutes(r *mux.Router, config *models.Config, userStorage models.UserSto
 		user.DisplayName = displayName
 
 		if err = userStorage.Save(); err != nil {
-			log.Print(err)
+			slog.ErrorContext(r.Context(), "Failed to save user to user database", "error", err)
 			view.RenderErrorView("Failed to save user to user database.", http.StatusInternalServerError, config, user, w)
 			return
 		}
 
-		http.Redirect(w, r, "/user", http.StatusFound)
+		http.Redirect(w, r, config.URLPath("/user"), http.StatusFound)
 	}).Methods(http.MethodPost)
 
 	r.HandleFunc("/user/password", func(w http.ResponseWriter, r *http.Request) {
@@ -98,18 +98,36 @@ func UserRoutes(r *mux.Router, config *models.Config, userStorage models.UserSto
 		}
 
 		if err := userStorage.SetUserPassword(user, newPassword); err != nil {
-			log.Print(err)
+			slog.ErrorContext(r.Context(), "Failed to update password", "error", err)
 			view.RenderErrorView("Failed to update password.", http.StatusInternalServerError, config, session.User, w)
 			return
 		}
 
 		if err := userStorage.Save(); err != nil {
-			log.Print(err)
+			slog.ErrorContext(r.Context(), "Failed to save user database", "error", err)
 			view.RenderErrorView("Failed to save user database.", http.StatusInternalServerError, config, session.User, w)
 			return
 		}
 
-		http.Redirect(w, r, "/user", http.StatusFound)
+		http.Redirect(w, r, config.URLPath("/user"), http.StatusFound)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/user/feed_token", func(w http.ResponseWriter, r *http.Request) {
+		user := models.GetRequestUser(r)
+
+		if err := user.ResetFeedToken(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to generate feed token", "error", err)
+			view.RenderErrorView("Failed to generate feed token.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		if err := userStorage.Save(); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save user database", "error", err)
+			view.RenderErrorView("Failed to save user database.", http.StatusInternalServerError, config, user, w)
+			return
+		}
+
+		http.Redirect(w, r, config.URLPath("/user"), http.StatusFound)
 	}).Methods(http.MethodPost)
 
 	r.HandleFunc("/user/delete", func(w http.ResponseWriter, r *http.Request) {
@@ -130,14 +148,16 @@ func UserRoutes(r *mux.Router, config *models.Config, userStorage models.UserSto
 		userStorage.DeleteUser(id)
 
 		if err = userStorage.Save(); err != nil {
-			log.Print(err)
+			slog.ErrorContext(r.Context(), "Failed to save user database", "error", err)
 			view.RenderErrorView("Failed to save user database.", http.StatusInternalServerError, config, user, w)
 			return
 		}
 
 		sessionStorage.RemoveSessionsForUser(id)
 
-		http.SetCookie(w, models.RemoveSessionCookie(false))
-		http.Redirect(w, r, "/", http.StatusFound)
+		webhookStorage.Dispatch(models.NewUserEvent(models.EventUserRemoved, user, user))
+
+		http.SetCookie(w, models.RemoveSessionCookie(config.IsHTTPS(r), config.BasePath))
+		http.Redirect(w, r, config.URLPath("/"), http.StatusFound)
 	}).Methods(http.MethodPost)
 }
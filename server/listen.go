@@ -0,0 +1,74 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"alexandria.app/models"
+)
+
+// systemdListenFDsStart is the first file descriptor passed by systemd's socket activation.
+const systemdListenFDsStart = 3
+
+// listen creates the listener configured by the config's Listen: a Unix socket for "unix:<path>", the socket
+// passed by systemd for "systemd" and a TCP listener on the config's Host and Port otherwise.
+func listen(config *models.Config) (net.Listener, error) {
+	switch {
+	case config.Listen == "systemd":
+		return systemdListener()
+	case strings.HasPrefix(config.Listen, "unix:"):
+		return unixListener(strings.TrimPrefix(config.Listen, "unix:"))
+	default:
+		return net.Listen("tcp", config.Host+config.Port)
+	}
+}
+
+// unixListener listens on the Unix socket at path. A socket left over by a previous process is removed.
+func unixListener(path string) (net.Listener, error) {
+	if stat, err := os.Stat(path); err == nil {
+		if stat.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The socket is usually shared with a reverse proxy running as another user of the same group.
+	if err = os.Chmod(path, 0660); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// systemdListener returns the first socket passed by systemd's socket activation, see sd_listen_fds(3).
+func systemdListener() (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, errors.New("no socket was passed by systemd, LISTEN_PID is not set to this process")
+	}
+
+	if fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS")); err != nil || fds < 1 {
+		return nil, errors.New("no socket was passed by systemd, LISTEN_FDS is not set")
+	}
+
+	// The variables must not be inherited by child processes.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(systemdListenFDsStart, "systemd")
+	defer file.Close()
+
+	return net.FileListener(file)
+}
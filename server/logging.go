@@ -0,0 +1,113 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"alexandria.app/crypto"
+	"alexandria.app/logging"
+	"alexandria.app/models"
+	"github.com/gorilla/mux"
+)
+
+// requestIDRegexp matches the request IDs which are taken over from trusted proxies.
+var requestIDRegexp = regexp.MustCompile(`^[\w.:-]{1,128}$`)
+
+// statusRecorder remembers the status code and the number of bytes written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap allows http.ResponseController to reach the original writer.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// requestID returns the ID of the request set by a trusted proxy, or a new random ID.
+func requestID(r *http.Request, config *models.Config) string {
+	if id := r.Header.Get(logging.RequestIDHeader); config.FromTrustedProxy(r) && requestIDRegexp.MatchString(id) {
+		return id
+	}
+
+	id, _ := crypto.GetRandomString(16)
+	return id
+}
+
+// accessLogHandler gives every request an ID, which is sent back in the X-Request-ID header and added to all log
+// lines written while handling the request, and logs and counts each request once it is handled.
+func accessLogHandler(next http.Handler, config *models.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &logging.RequestInfo{ID: requestID(r, config)}
+		w.Header().Set(logging.RequestIDHeader, info.ID)
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		ctx := logging.WithRequestInfo(r.Context(), info)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		duration := time.Since(start)
+		observeRequest(r, info.Route, recorder.status, duration)
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", info.Route),
+			slog.Int("status", recorder.status),
+			slog.Int64("bytes", recorder.bytes),
+			slog.Duration("duration", duration),
+			slog.String("remote", r.RemoteAddr),
+		}
+		if info.UserID != 0 {
+			attrs = append(attrs, slog.Uint64("user_id", uint64(info.UserID)))
+		}
+
+		// Orchestrators check the health every few seconds, which would drown all other requests.
+		level := slog.LevelInfo
+		if info.Route == "/healthz" || info.Route == "/readyz" {
+			level = slog.LevelDebug
+		}
+
+		slog.LogAttrs(ctx, level, "request", attrs...)
+	})
+}
+
+// requestInfoMiddleware records the matched route and the logged-in user in the request's info, so that they
+// show up in the access log. It has to run after the routes.AuthMiddleWare.
+func requestInfoMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info := logging.GetRequestInfo(r.Context()); info != nil {
+			if route := mux.CurrentRoute(r); route != nil {
+				info.Route, _ = route.GetPathTemplate()
+			}
+			if user := models.GetRequestUser(r); user != nil {
+				info.UserID = user.ID
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"alexandria.app/metrics"
+	"alexandria.app/models"
+)
+
+var (
+	httpRequests = metrics.NewCounter("alexandria_http_requests_total",
+		"Number of handled HTTP requests by route, method and status code.", "route", "method", "status")
+	httpDurations = metrics.NewHistogram("alexandria_http_request_duration_seconds",
+		"Duration of handling HTTP requests by route and method.", metrics.DefBuckets, "route", "method")
+)
+
+// observeRequest records the request in the HTTP metrics. Requests which didn't match a route are counted together,
+// so that random paths don't create new series.
+func observeRequest(r *http.Request, route string, status int, duration time.Duration) {
+	if len(route) == 0 {
+		route = "unmatched"
+	}
+
+	httpRequests.Inc(route, r.Method, strconv.Itoa(status))
+	httpDurations.Observe(duration.Seconds(), route, r.Method)
+}
+
+// contentStatsMaxAge is how long the content stats are reused, which is long enough for all gauges of a scrape.
+const contentStatsMaxAge = 5 * time.Second
+
+// contentStats caches the result of models.ContentStats, so that the gauges of a scrape share a single walk of
+// the content directory.
+type contentStats struct {
+	config *models.Config
+	mutex  sync.Mutex
+	taken  time.Time
+	count  int
+	size   int64
+}
+
+func (s *contentStats) get() (int, int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if time.Since(s.taken) >= contentStatsMaxAge {
+		s.count, s.size, _ = models.ContentStats(s.config)
+		s.taken = time.Now()
+	}
+
+	return s.count, s.size
+}
+
+// registerGauges adds the metrics which are read from the storages whenever the metrics are requested.
+func registerGauges(sessionStorage *models.SessionStorage, config *models.Config) {
+	metrics.NewGaugeFunc("alexandria_sessions", "Number of active sessions.", func() float64 {
+		return float64(sessionStorage.Count())
+	})
+
+	stats := &contentStats{config: config}
+
+	metrics.NewGaugeFunc("alexandria_articles", "Number of articles.", func() float64 {
+		count, _ := stats.get()
+		return float64(count)
+	})
+
+	metrics.NewGaugeFunc("alexandria_content_bytes", "Total size of all articles in bytes, without attachments.", func() float64 {
+		_, size := stats.get()
+		return float64(size)
+	})
+}
+
+// metricsHandler serves the metrics. If the config's MetricsToken is set, it has to be sent as bearer token.
+func metricsHandler(config *models.Config) http.Handler {
+	handler := metrics.DefaultRegistry.Handler()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(config.MetricsToken) != 0 {
+			token := []byte("Bearer " + config.MetricsToken)
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), token) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
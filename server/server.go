@@ -1,8 +1,14 @@
 package server
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"alexandria.app/models"
 	"alexandria.app/routes"
@@ -10,23 +16,16 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Println(r.RequestURI)
-		next.ServeHTTP(w, r)
-	})
-}
-
-func authedUserMiddleware(userStorage models.UserStorage) mux.MiddlewareFunc {
+func authedUserMiddleware(config *models.Config, userStorage models.UserStorage) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if userStorage.IsEmpty() {
-				http.Redirect(w, r, "/setup", http.StatusFound)
+				http.Redirect(w, r, config.URLPath("/setup"), http.StatusFound)
 				return
 			}
 
 			if models.GetRequestUser(r) == nil {
-				http.Redirect(w, r, "/login", http.StatusFound)
+				http.Redirect(w, r, config.URLPath("/login"), http.StatusFound)
 				return
 			}
 
@@ -49,38 +48,137 @@ func authedAdminMiddleware(config *models.Config) mux.MiddlewareFunc {
 	}
 }
 
-// Start will setup all HTTP routes and start the HTTP server.
-func Start(userStorage models.UserStorage, sessionStorage *models.SessionStorage, config *models.Config) {
-	r := mux.NewRouter()
+// newServer creates an HTTP server with the config's timeouts.
+func newServer(handler http.Handler, config *models.Config) *http.Server {
+	return &http.Server{
+		Handler:      handler,
+		ReadTimeout:  config.ReadTimeout.Duration(),
+		WriteTimeout: config.WriteTimeout.Duration(),
+		IdleTimeout:  config.IdleTimeout.Duration(),
+	}
+}
+
+// Start will setup all HTTP routes and serve them until the process receives SIGINT or SIGTERM.
+// Then the server stops accepting connections, waits for running requests to finish and saves the storages.
+func Start(userStorage models.UserStorage, sessionStorage *models.SessionStorage, webhookStorage *models.WebhookStorage, revisionStorage *models.RevisionStorage, config *models.Config) error {
+	if err := view.LoadTemplates("layout", config); err != nil {
+		return err
+	}
+
+	root := mux.NewRouter()
+	r := root
+
+	// All routes are mounted below the base URL's path, e.g. /wiki/ for https://example.com/wiki/.
+	if config.BasePath != "/" {
+		prefix := strings.TrimSuffix(config.BasePath, "/")
+		root.Handle(prefix, http.RedirectHandler(config.BasePath, http.StatusMovedPermanently))
+		r = root.PathPrefix(prefix).Subrouter()
+	}
+
+	// Health checks are mounted at the root, so that they are found without knowing the base path.
+	routes.HealthRoutes(root, config, userStorage)
 
-	r.Use(loggingMiddleware)
+	root.Use(hstsMiddleware(config))
 
-	r.Use(routes.AuthMiddleWare(sessionStorage))
+	root.Use(routes.AuthMiddleWare(sessionStorage))
+
+	root.Use(requestInfoMiddleware)
 
 	authedUser := r.PathPrefix("").Subrouter()
-	authedUser.Use(authedUserMiddleware(userStorage))
+	authedUser.Use(authedUserMiddleware(config, userStorage))
 
 	authedAdmin := authedUser.PathPrefix("").Subrouter()
 	authedAdmin.Use(authedAdminMiddleware(config))
 
 	setup := r.PathPrefix("/setup").Subrouter()
-	routes.SetupRoutes(setup, config, userStorage, sessionStorage)
+	routes.SetupRoutes(setup, config, userStorage, sessionStorage, webhookStorage)
 
 	routes.IndexRoutes(r, config, userStorage)
 
 	// Session-related routes.
 	routes.LoginRoutes(r, config, userStorage, sessionStorage)
-	routes.LogoutRoutes(authedUser, sessionStorage)
+	routes.LogoutRoutes(authedUser, config, sessionStorage)
 
 	// User-related routes.
-	routes.AdminRoutes(authedAdmin, config, userStorage, sessionStorage)
-	routes.UserRoutes(authedUser, config, userStorage, sessionStorage)
+	routes.AdminRoutes(authedAdmin, config, userStorage, sessionStorage, webhookStorage)
+	routes.UserRoutes(authedUser, config, userStorage, sessionStorage, webhookStorage)
 
 	// Content-related routes.
-	routes.ArticleRoutes(authedUser, config)
+	routes.ArticleRoutes(authedUser, config, webhookStorage, revisionStorage)
+	routes.AttachmentRoutes(authedUser, config)
+	routes.RevisionRoutes(authedUser, config, webhookStorage, revisionStorage)
+	routes.RecentRoutes(authedUser, config, userStorage, revisionStorage)
+	routes.FeedRoutes(r, config, userStorage, revisionStorage)
 
 	// Asset-related routes
 	routes.AssetRoutes(r, config)
 
-	log.Fatal(http.ListenAndServe(config.Host+config.Port, r))
+	// The metrics are either served on their own listener, which shouldn't be reachable from outside,
+	// or together with the wiki if they are protected by a token.
+	registerGauges(sessionStorage, config)
+	if len(config.MetricsAddress) == 0 && len(config.MetricsToken) != 0 {
+		r.Handle("/metrics", metricsHandler(config)).Methods(http.MethodGet)
+	}
+
+	listener, err := listen(config)
+	if err != nil {
+		return err
+	}
+
+	server := newServer(accessLogHandler(root, config), config)
+	servers := []*http.Server{server}
+	errs := make(chan error, 3)
+
+	if config.TLSEnabled() {
+		if server.TLSConfig, err = newTLSConfig(config); err != nil {
+			listener.Close()
+			return err
+		}
+
+		if len(config.HTTPRedirectPort) != 0 {
+			redirect := newServer(httpsRedirect(config), config)
+			redirect.Addr = config.Host + config.HTTPRedirectPort
+			servers = append(servers, redirect)
+
+			go func() { errs <- redirect.ListenAndServe() }()
+		}
+
+		go func() { errs <- server.ServeTLS(listener, "", "") }()
+	} else {
+		go func() { errs <- server.Serve(listener) }()
+	}
+
+	if len(config.MetricsAddress) != 0 {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metricsHandler(config))
+
+		metricsServer := newServer(metricsMux, config)
+		metricsServer.Addr = config.MetricsAddress
+		servers = append(servers, metricsServer)
+
+		go func() { errs <- metricsServer.ListenAndServe() }()
+	}
+
+	slog.Info("Listening", "address", listener.Addr().String())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err = <-errs:
+		return err
+	case sig := <-signals:
+		slog.Info("Shutting down", "signal", sig.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout.Duration())
+	defer cancel()
+
+	for _, s := range servers {
+		if err = s.Shutdown(ctx); err != nil {
+			slog.Error("Failed to wait for running requests", "error", err)
+		}
+	}
+
+	return errors.Join(userStorage.Save(), sessionStorage.Save(), webhookStorage.Save())
 }
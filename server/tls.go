@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"alexandria.app/models"
+)
+
+// certificateReloader holds the TLS certificate and loads it again from disk on SIGHUP,
+// so that renewed certificates are picked up without restarting the server.
+type certificateReloader struct {
+	certPath string
+	keyPath  string
+	mutex    sync.RWMutex
+	cert     *tls.Certificate
+}
+
+func newCertificateReloader(certPath, keyPath string) (*certificateReloader, error) {
+	c := &certificateReloader{certPath: certPath, keyPath: keyPath}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *certificateReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+
+	c.mutex.Lock()
+	c.cert = &cert
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// watch reloads the certificate whenever the process receives SIGHUP. If the new certificate can't be loaded
+// the old one is kept.
+func (c *certificateReloader) watch() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		if err := c.reload(); err != nil {
+			slog.Error("Failed to reload TLS certificate", "error", err)
+			continue
+		}
+		slog.Info("Reloaded TLS certificate")
+	}
+}
+
+func (c *certificateReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cert, nil
+}
+
+// hstsMiddleware tells browsers to only use HTTPS for the wiki from now on. The header is only sent with
+// HTTPS responses, as browsers ignore it otherwise.
+func hstsMiddleware(config *models.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.HSTSMaxAge > 0 && config.IsHTTPS(r) {
+				w.Header().Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d", config.HSTSMaxAge))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// httpsRedirect redirects all requests to the same URL on the HTTPS port.
+func httpsRedirect(config *models.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		if config.Port != ":443" {
+			host += config.Port
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// newTLSConfig loads the config's certificate and returns the TLS config serving it. The certificate is loaded
+// again on SIGHUP.
+func newTLSConfig(config *models.Config) (*tls.Config, error) {
+	certs, err := newCertificateReloader(config.TLSCert, config.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	go certs.watch()
+
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.getCertificate,
+	}, nil
+}
@@ -0,0 +1,145 @@
+package view
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"alexandria.app/models"
+)
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string     `xml:"title"`
+	ID      string     `xml:"id"`
+	Link    atomLink   `xml:"link"`
+	Updated string     `xml:"updated"`
+	Author  atomAuthor `xml:"author"`
+	Summary string     `xml:"summary"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Author      string `xml:"author"`
+	Description string `xml:"description"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// RevisionURL returns the absolute URL under which the diff of the revision can be viewed.
+func RevisionURL(config *models.Config, rev *models.Revision) string {
+	return fmt.Sprintf("%sdiff/%s?rev=%d", config.BaseURL, (&url.URL{Path: rev.Path}).EscapedPath(), rev.ID)
+}
+
+func revisionTitle(rev *models.Revision) string {
+	title := fmt.Sprintf("%s (%s)", rev.Path, rev.Event)
+	if len(rev.OldPath) != 0 {
+		title = fmt.Sprintf("%s → %s (%s)", rev.OldPath, rev.Path, rev.Event)
+	}
+
+	return title
+}
+
+func revisionSummary(rev *models.Revision) string {
+	return fmt.Sprintf("%s (+%d -%d)", rev.Summary, rev.Diff.Added, rev.Diff.Removed)
+}
+
+// RenderAtomFeed writes the revisions as Atom feed to the io.Writer.
+func RenderAtomFeed(w io.Writer, config *models.Config, revisions []*models.Revision) error {
+	updated := time.Now()
+	if len(revisions) != 0 {
+		updated = revisions[0].Time()
+	}
+
+	feed := atomFeed{
+		Title:   "Alexandria: Recent changes",
+		ID:      config.BaseURL + "recent",
+		Links:   []atomLink{{Href: config.BaseURL + "recent"}},
+		Updated: updated.UTC().Format(time.RFC3339),
+		Entries: make([]atomEntry, len(revisions)),
+	}
+
+	for i, rev := range revisions {
+		link := RevisionURL(config, rev)
+
+		feed.Entries[i] = atomEntry{
+			Title:   revisionTitle(rev),
+			ID:      link,
+			Link:    atomLink{Href: link},
+			Updated: rev.Time().UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: rev.AuthorName},
+			Summary: revisionSummary(rev),
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	return xml.NewEncoder(w).Encode(&feed)
+}
+
+// RenderRSSFeed writes the revisions as RSS 2.0 feed to the io.Writer.
+func RenderRSSFeed(w io.Writer, config *models.Config, revisions []*models.Revision) error {
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       "Alexandria: Recent changes",
+			Link:        config.BaseURL + "recent",
+			Description: "Recent changes to all articles of the wiki.",
+			Items:       make([]rssItem, len(revisions)),
+		},
+	}
+
+	for i, rev := range revisions {
+		link := RevisionURL(config, rev)
+
+		feed.Channel.Items[i] = rssItem{
+			Title:       revisionTitle(rev),
+			Link:        link,
+			GUID:        link,
+			PubDate:     rev.Time().UTC().Format(time.RFC1123Z),
+			Author:      rev.AuthorName,
+			Description: revisionSummary(rev),
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	return xml.NewEncoder(w).Encode(&feed)
+}
@@ -1,9 +1,20 @@
 package view
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"errors"
+	"fmt"
 	"html/template"
 	"io"
-	"path/filepath"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
 
 	"alexandria.app/models"
 )
@@ -21,13 +32,73 @@ type viewDataWrapper struct {
 	User   *models.User
 }
 
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// ErrTemplatesNotLoaded is returned by TemplatesLoaded if LoadTemplates didn't succeed yet.
+var ErrTemplatesNotLoaded = errors.New("the templates aren't loaded")
+
+// templates caches the parsed templates by their files, so that they are only parsed once. loaded records the
+// layouts and template directories for which LoadTemplates parsed all templates.
+var templates = struct {
+	sync.RWMutex
+	byFiles map[string]*template.Template
+	loaded  map[string]bool
+}{byFiles: map[string]*template.Template{}, loaded: map[string]bool{}}
+
+// templateFiles returns the directory with the templates. The embedded templates are used unless the config's
+// TemplateDirectory is set, e.g. for theming.
+func templateFiles(config *models.Config) fs.FS {
+	if len(config.TemplateDirectory) != 0 {
+		return os.DirFS(config.TemplateDirectory)
+	}
+
+	files, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		panic(err)
+	}
+
+	return files
+}
+
+func (v *View) files() []string {
+	return []string{v.layout + ".html", v.template + ".html"}
+}
+
+// parse returns the parsed template of the view. Templates are parsed once and then cached,
+// unless the config's DevMode is enabled, in which case changes to the templates are picked up immediately.
+func (v *View) parse() (*template.Template, error) {
+	files := v.files()
+
+	if v.config.DevMode {
+		return template.New(v.layout).ParseFS(templateFiles(v.config), files...)
+	}
+
+	key := strings.Join(append([]string{v.config.TemplateDirectory}, files...), "\n")
+
+	templates.RLock()
+	tmpl, ok := templates.byFiles[key]
+	templates.RUnlock()
+
+	if ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := template.New(v.layout).ParseFS(templateFiles(v.config), files...)
+	if err != nil {
+		return nil, err
+	}
+
+	templates.Lock()
+	templates.byFiles[key] = tmpl
+	templates.Unlock()
+
+	return tmpl, nil
+}
+
 // Render exectutes the template and writes the resulting HTML to the io.Writer.
 func (v *View) Render(w io.Writer, user *models.User, data interface{}) error {
-	tmpl, err := template.New(v.layout).ParseFiles(
-		filepath.Join(v.config.TemplateDirectory, v.layout+".html"),
-		filepath.Join(v.config.TemplateDirectory, v.template+".html"),
-	)
-
+	tmpl, err := v.parse()
 	if err != nil {
 		return err
 	}
@@ -39,6 +110,63 @@ func (v *View) Render(w io.Writer, user *models.User, data interface{}) error {
 	})
 }
 
+// Serve renders the view and sends it with an ETag and the modification time, so that browsers can revalidate
+// their copy. If the page didn't change since the browser requested it, only "304 Not Modified" is sent.
+func (v *View) Serve(w http.ResponseWriter, r *http.Request, user *models.User, data interface{}, modTime time.Time) error {
+	var buf bytes.Buffer
+	if err := v.Render(&buf, user, data); err != nil {
+		return err
+	}
+
+	// The page also depends on the user, e.g. on whether they are an admin, which the ETag covers
+	// as it is calculated from the whole page.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(buf.Bytes())))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Vary", "Cookie")
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(buf.Bytes()))
+
+	return nil
+}
+
+// LoadTemplates parses all page templates in the config's template directory together with the layout,
+// so that broken templates are noticed on startup instead of when they are first used.
+func LoadTemplates(layout string, config *models.Config) error {
+	files, err := fs.ReadDir(templateFiles(config), ".")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".html")
+		if file.IsDir() || name == layout || path.Ext(file.Name()) != ".html" {
+			continue
+		}
+
+		if _, err := New(layout, name, config).parse(); err != nil {
+			return err
+		}
+	}
+
+	templates.Lock()
+	templates.loaded[config.TemplateDirectory+"\n"+layout] = true
+	templates.Unlock()
+
+	return nil
+}
+
+// TemplatesLoaded checks that LoadTemplates parsed all templates with the layout, without parsing them again.
+func TemplatesLoaded(layout string, config *models.Config) error {
+	templates.RLock()
+	defer templates.RUnlock()
+
+	if !templates.loaded[config.TemplateDirectory+"\n"+layout] {
+		return ErrTemplatesNotLoaded
+	}
+
+	return nil
+}
+
 // New creates a new View struct from the layout, template and config.
 func New(layout, template string, config *models.Config) *View {
 	return &View{